@@ -0,0 +1,396 @@
+package googleoauth2callback
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const googleUserInfoURL = "https://openidconnect.googleapis.com/v1/userinfo"
+
+var ErrWrongAccount = errors.New("authenticated with an unexpected account")
+
+type Account struct {
+	Key       string
+	Email     string
+	ClientID  string
+	Scopes    []string
+	UpdatedAt time.Time
+}
+
+type TokenLister interface {
+	List(ctx context.Context) ([]*StoredToken, error)
+}
+
+func WithAccountSelector(selector func(accounts []Account) (Account, error)) Option {
+	return func(o *OAuth2Callback) {
+		o.accountSelector = selector
+	}
+}
+
+func PromptAccountSelector(accounts []Account) (Account, error) {
+	fmt.Fprintln(os.Stderr, "Multiple accounts are available:")
+	for i, account := range accounts {
+		label := account.Email
+		if label == "" {
+			label = account.Key
+		}
+		fmt.Fprintf(os.Stderr, "  [%d] %s\n", i+1, label)
+	}
+	fmt.Fprint(os.Stderr, "Select an account: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return Account{}, fmt.Errorf("failed to read selection: %v", err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(accounts) {
+		return Account{}, fmt.Errorf("invalid selection: %q", strings.TrimSpace(line))
+	}
+	return accounts[n-1], nil
+}
+
+func (o *OAuth2Callback) Accounts(ctx context.Context) ([]Account, error) {
+	if o.tokenStore != nil {
+		lister, ok := o.tokenStore.(TokenLister)
+		if !ok {
+			return nil, fmt.Errorf("token store does not support listing accounts")
+		}
+		stored, err := lister.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		accounts := make([]Account, 0, len(stored))
+		for _, s := range stored {
+			if strings.HasSuffix(s.Key, previousTokenSuffix) {
+				continue
+			}
+			accounts = append(accounts, Account{
+				Key:       s.Key,
+				Email:     s.AccountEmail,
+				ClientID:  s.ClientID,
+				Scopes:    s.Scopes,
+				UpdatedAt: s.UpdatedAt,
+			})
+		}
+		return accounts, nil
+	}
+
+	if o.tokenPath != "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(o.tokenDir(), "token-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, 0, len(paths))
+	for _, path := range paths {
+		account := Account{
+			Key: strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "token-"), ".json"),
+		}
+		if info, err := os.Stat(path); err == nil {
+			account.UpdatedAt = info.ModTime()
+		}
+		if b, err := os.ReadFile(path); err == nil {
+			if file, _, err := decodeTokenFile(b); err == nil {
+				account.Email = file.AccountEmail
+			}
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// selectAccount lets the account selector pick which cached identity to use
+// and points the token namespace at it.
+func (o *OAuth2Callback) selectAccount(ctx context.Context) error {
+	accounts, err := o.Accounts(ctx)
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+	account, err := o.accountSelector(accounts)
+	if err != nil {
+		return fmt.Errorf("failed to select account: %v", err)
+	}
+	o.tokenNamespace = account.Key
+	return nil
+}
+
+// CurrentAccount describes the Google account the cached token belongs to. The
+// email is recorded when the token is issued, so tokens saved by earlier
+// releases or without an email-capable scope have an empty Email.
+func (o *OAuth2Callback) CurrentAccount(ctx context.Context) (Account, error) {
+	tok, err := o.loadToken(ctx)
+	if err != nil {
+		return Account{}, err
+	}
+	return o.accountOf(tok)
+}
+
+// accountOf describes the account of tok, which has just been loaded.
+func (o *OAuth2Callback) accountOf(tok *oauth2.Token) (Account, error) {
+	key, err := o.resolveTokenNamespace()
+	if err != nil {
+		return Account{}, err
+	}
+	account := Account{
+		Key:    key,
+		Email:  o.currentAccountEmail(),
+		Scopes: o.scopes,
+	}
+	if scope := RawTokenResponse(tok).Scope; scope != "" {
+		account.Scopes = strings.Fields(scope)
+	}
+	if _, creds, err := o.readCredentials(); err == nil {
+		account.ClientID = creds.Web.ClientID
+	}
+	if o.tokenStore == nil {
+		if tokenPath, err := o.resolveTokenPath(); err == nil {
+			if info, err := os.Stat(tokenPath); err == nil {
+				account.UpdatedAt = info.ModTime()
+			}
+		}
+	}
+	return account, nil
+}
+
+// WithExpectedAccount requires the token to belong to the given Google account.
+// A newly issued token for any other account is discarded and the flow fails
+// with ErrWrongAccount; a cached token recorded for another account is deleted.
+func WithExpectedAccount(email string) Option {
+	return func(o *OAuth2Callback) {
+		o.expectedAccount = email
+	}
+}
+
+// checkExpectedAccount reports whether email is the expected account. An
+// unknown email does not match, since the identity cannot be confirmed.
+func (o *OAuth2Callback) checkExpectedAccount(email string) error {
+	if o.expectedAccount == "" || strings.EqualFold(email, o.expectedAccount) {
+		return nil
+	}
+	if email == "" {
+		return fmt.Errorf("%w: could not determine the account, want %s", ErrWrongAccount, o.expectedAccount)
+	}
+	return fmt.Errorf("%w: got %s, want %s", ErrWrongAccount, email, o.expectedAccount)
+}
+
+// requireEmailScope adds the email scope unless it is already requested, so
+// that the account of every issued token can be checked. Cached tokens issued
+// without it are looked up through userinfo, or replaced by a new flow if that
+// is not possible.
+func (o *OAuth2Callback) requireEmailScope() {
+	if slices.Contains(o.scopes, "email") || slices.Contains(o.scopes, scopeAliases["email"]) {
+		return
+	}
+	o.scopes = append(slices.Clone(o.scopes), "email")
+	o.addedEmailScope = true
+}
+
+// namespaceScopes returns the scopes the token namespace is derived from. The
+// email scope added for WithExpectedAccount is left out, so that turning the
+// option on keeps using the tokens already cached for the configured scopes.
+func (o *OAuth2Callback) namespaceScopes() []string {
+	if !o.addedEmailScope {
+		return o.scopes
+	}
+	return slices.DeleteFunc(slices.Clone(o.scopes), func(scope string) bool { return scope == "email" })
+}
+
+var errNoAccountEmail = errors.New("userinfo returned no email; the token was issued without the email scope")
+
+// accountLookupError reports that the account of a cached token could not be
+// looked up for a reason that may go away, such as a network failure. Unlike
+// ErrWrongAccount it does not call for a new authorization flow.
+type accountLookupError struct {
+	err error
+}
+
+func (e *accountLookupError) Error() string {
+	return fmt.Sprintf("failed to determine the account of the cached token: %v", e.err)
+}
+
+func (e *accountLookupError) Unwrap() error {
+	return e.err
+}
+
+// checkCachedAccount checks a loaded token against the expected account and
+// deletes it when it belongs to another one. Tokens cached before the account
+// email was recorded are looked up through userinfo, refreshing the access
+// token if needed, and saved again with the email they turn out to have.
+func (o *OAuth2Callback) checkCachedAccount(ctx context.Context, tok *oauth2.Token) (*oauth2.Token, error) {
+	email := o.currentAccountEmail()
+	if email == "" {
+		resolved, err := o.resolveCachedAccountEmail(ctx, tok)
+		if errors.Is(err, errNoAccountEmail) || isInvalidGrant(err) {
+			return nil, fmt.Errorf("%w: could not determine the account of the cached token: %v", ErrWrongAccount, err)
+		}
+		if err != nil {
+			return nil, &accountLookupError{err: err}
+		}
+		tok, email = resolved, o.currentAccountEmail()
+		if o.checkExpectedAccount(email) == nil {
+			if err := o.saveToken(ctx, tok); err != nil {
+				o.warn(fmt.Errorf("failed to record the account of the cached token: %v", err))
+			}
+		}
+	}
+	if err := o.checkExpectedAccount(email); err != nil {
+		if deleteErr := o.deleteToken(ctx); deleteErr != nil {
+			o.warn(fmt.Errorf("failed to delete token for %s: %v", email, deleteErr))
+		}
+		o.setAccountEmail("")
+		return nil, err
+	}
+	return tok, nil
+}
+
+func (o *OAuth2Callback) resolveCachedAccountEmail(ctx context.Context, tok *oauth2.Token) (*oauth2.Token, error) {
+	config, err := o.createOAuth2Config()
+	if err != nil {
+		return nil, err
+	}
+	ctx = o.tokenEndpointContext(ctx)
+	fresh, err := config.TokenSource(ctx, tok).Token()
+	if err != nil {
+		return nil, err
+	}
+	userInfoURL, err := o.userInfoURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	email, err := fetchUserInfoEmail(ctx, userInfoURL, fresh)
+	if err != nil {
+		return nil, err
+	}
+	if email == "" {
+		return nil, errNoAccountEmail
+	}
+	o.setAccountEmail(email)
+	return fresh, nil
+}
+
+func (o *OAuth2Callback) deleteToken(ctx context.Context) error {
+	if o.tokenStore != nil {
+		key, err := o.resolveTokenNamespace()
+		if err != nil {
+			return err
+		}
+		return o.deleteStoredToken(ctx, key)
+	}
+	tokenPath, err := o.resolveTokenPath()
+	if err != nil {
+		return err
+	}
+	return removeTokenFile(tokenPath)
+}
+
+// deleteStoredToken deletes the token stored under key along with its
+// previous refresh token backup.
+func (o *OAuth2Callback) deleteStoredToken(ctx context.Context, key string) error {
+	for _, k := range []string{key, key + previousTokenSuffix} {
+		if err := o.tokenStore.Delete(ctx, k); err != nil && !errors.Is(err, ErrTokenNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeTokenFile(path string) error {
+	for _, p := range []string{path, path + previousTokenSuffix} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *OAuth2Callback) currentAccountEmail() string {
+	o.statusMu.Lock()
+	defer o.statusMu.Unlock()
+	return o.accountEmail
+}
+
+func (o *OAuth2Callback) setAccountEmail(email string) {
+	o.statusMu.Lock()
+	defer o.statusMu.Unlock()
+	o.accountEmail = email
+}
+
+// lookupAccountEmail finds the email of the account a freshly issued token
+// belongs to, from the verified id_token claims, the id_token in the response
+// or the userinfo endpoint, in that order. It returns "" when none of them is
+// available; failures only produce a warning.
+func (o *OAuth2Callback) lookupAccountEmail(ctx context.Context, tok *oauth2.Token) string {
+	if claims := o.IDTokenClaims(); claims != nil && claims.Email != "" {
+		return claims.Email
+	}
+	// The id_token came straight from the token endpoint over TLS, so its
+	// claims can be read without checking the signature.
+	if raw := RawTokenResponse(tok).IDToken; raw != "" {
+		parts := strings.Split(raw, ".")
+		var claims rawIDTokenClaims
+		if len(parts) == 3 && decodeSegment(parts[1], &claims) == nil && claims.Email != "" {
+			return claims.Email
+		}
+	}
+	if !o.grantsEmail(tok) {
+		return ""
+	}
+	userInfoURL, err := o.userInfoURL(ctx)
+	if err != nil {
+		o.warn(fmt.Errorf("failed to look up account email: %v", err))
+		return ""
+	}
+	email, err := fetchUserInfoEmail(ctx, userInfoURL, tok)
+	if err != nil {
+		o.warn(fmt.Errorf("failed to look up account email: %v", err))
+		return ""
+	}
+	return email
+}
+
+func (o *OAuth2Callback) grantsEmail(tok *oauth2.Token) bool {
+	scopes := o.scopes
+	if scope := RawTokenResponse(tok).Scope; scope != "" {
+		scopes = strings.Fields(scope)
+	}
+	return slices.Contains(scopes, "email") || slices.Contains(scopes, scopeAliases["email"])
+}
+
+func fetchUserInfoEmail(ctx context.Context, userInfoURL string, tok *oauth2.Token) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, userInfoURL, nil)
+	if err != nil {
+		return "", err
+	}
+	tok.SetAuthHeader(req)
+	resp, err := contextClient(ctx).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("userinfo request failed: %s", resp.Status)
+	}
+	var info struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to decode userinfo response: %v", err)
+	}
+	return info.Email, nil
+}
@@ -0,0 +1,152 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestExpectedAccountAddsEmailScope(t *testing.T) {
+	o := New(WithScopes([]string{"https://www.googleapis.com/auth/drive"}), WithExpectedAccount("ops@example.com"))
+	if !slices.Contains(o.scopes, "email") {
+		t.Errorf("scopes = %v, want email added", o.scopes)
+	}
+	o = New(WithScopes([]string{scopeAliases["email"]}), WithExpectedAccount("ops@example.com"))
+	if len(o.scopes) != 1 {
+		t.Errorf("scopes = %v, want email not added twice", o.scopes)
+	}
+}
+
+func TestExpectedAccountResolvesLegacyToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		wantErr     bool
+		wantDeleted bool
+	}{
+		{name: "matching account", email: "ops@example.com"},
+		{name: "other account", email: "me@gmail.com", wantErr: true, wantDeleted: true},
+		{name: "no email scope", email: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userinfo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer at" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"sub":"1","email":%q}`, tt.email)
+			}))
+			defer userinfo.Close()
+
+			dir := t.TempDir()
+			credentialsPath := filepath.Join(dir, "credentials.json")
+			creds := `{"web":{"client_id":"cid","client_secret":"sec","redirect_uris":["http://localhost:8080/callback"]}}`
+			if err := os.WriteFile(credentialsPath, []byte(creds), 0600); err != nil {
+				t.Fatal(err)
+			}
+			tokenPath := filepath.Join(dir, "token.json")
+			o := New(
+				WithCredentialsPath(credentialsPath),
+				WithTokenPath(tokenPath),
+				WithRedirectURL("http://localhost:8080/callback"),
+				WithExpectedAccount("ops@example.com"),
+			)
+			// A token saved without an account email, as older versions did.
+			if err := o.saveToken(context.Background(), &oauth2.Token{AccessToken: "at", RefreshToken: "rt", Expiry: time.Now().Add(time.Hour)}); err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: rewriteTransport{userinfo.URL}})
+
+			_, err := o.loadToken(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrWrongAccount) {
+				t.Errorf("error = %v, want ErrWrongAccount", err)
+			}
+			_, statErr := os.Stat(tokenPath)
+			if deleted := errors.Is(statErr, os.ErrNotExist); deleted != tt.wantDeleted {
+				t.Errorf("token deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if !tt.wantErr {
+				b, _ := os.ReadFile(tokenPath)
+				file, _, err := decodeTokenFile(b)
+				if err != nil || file.AccountEmail != tt.email {
+					t.Errorf("saved account email = %q, want %q (%v)", file.AccountEmail, tt.email, err)
+				}
+			}
+		})
+	}
+}
+
+// rewriteTransport sends every request to base, keeping the path.
+type rewriteTransport struct {
+	base string
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := t.base + req.URL.Path
+	r, err := http.NewRequestWithContext(req.Context(), req.Method, u, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Header = req.Header
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestExpectedAccountLookupFailureKeepsToken(t *testing.T) {
+	userinfo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer userinfo.Close()
+
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "token.json")
+	o := New(
+		WithCredentialsPath(writeCredentials(t, dir, userinfo.URL+"/token", "http://localhost:8080/callback")),
+		WithTokenPath(tokenPath),
+		WithRedirectURL("http://localhost:8080/callback"),
+		WithExpectedAccount("ops@example.com"),
+		WithReadyHook(func(string) { t.Error("an authorization flow was started") }),
+	)
+	if err := o.saveToken(context.Background(), &oauth2.Token{AccessToken: "at", RefreshToken: "rt", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: rewriteTransport{userinfo.URL}})
+
+	_, err := o.GetClientContext(ctx)
+	if err == nil || errors.Is(err, ErrWrongAccount) {
+		t.Errorf("GetClientContext error = %v, want a lookup failure other than ErrWrongAccount", err)
+	}
+	if _, err := os.Stat(tokenPath); err != nil {
+		t.Errorf("token was deleted: %v", err)
+	}
+}
+
+func TestExpectedAccountKeepsTokenNamespace(t *testing.T) {
+	dir := t.TempDir()
+	credentialsPath := writeCredentials(t, dir, "https://oauth2.googleapis.com/token", "http://localhost:8080/callback")
+	scopes := []string{"https://www.googleapis.com/auth/drive"}
+	before, err := New(WithCredentialsPath(credentialsPath), WithScopes(scopes)).resolveTokenNamespace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := New(WithCredentialsPath(credentialsPath), WithScopes(scopes), WithExpectedAccount("ops@example.com")).resolveTokenNamespace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != after {
+		t.Errorf("namespace changed from %s to %s when WithExpectedAccount added the email scope", before, after)
+	}
+}
@@ -0,0 +1,36 @@
+package googleoauth2callback
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// writePrivateFile writes data to path so that only the current user can read
+// it at any point: the data goes to a 0600 temporary file in the same
+// directory, which is restricted to its owner before being renamed into place.
+func writePrivateFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := restrictToOwner(tmp); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
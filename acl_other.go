@@ -0,0 +1,9 @@
+//go:build !windows
+
+package googleoauth2callback
+
+// restrictToOwner is a no-op outside Windows; writePrivateFile creates files
+// with mode 0600, which already limits access to the owner.
+func restrictToOwner(path string) error {
+	return nil
+}
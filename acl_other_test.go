@@ -0,0 +1,33 @@
+//go:build !windows
+
+package googleoauth2callback
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWritePrivateFileMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePrivateFile(path, []byte(`{"access_token":"x"}`)); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("mode = %o, want 600", mode)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"access_token":"x"}` {
+		t.Errorf("content = %s", b)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build windows
+
+package googleoauth2callback
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sddlRevision1                    = 1
+	daclSecurityInformation          = 0x00000004
+	protectedDaclSecurityInformation = 0x80000000
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procConvertStringSecurityDescriptor = advapi32.NewProc("ConvertStringSecurityDescriptorToSecurityDescriptorW")
+	procSetFileSecurity                 = advapi32.NewProc("SetFileSecurityW")
+	procLocalFree                       = kernel32.NewProc("LocalFree")
+)
+
+// restrictToOwner replaces the file's DACL with one that grants access to the
+// current user only and does not inherit entries from the parent directory.
+// File modes such as 0600 have no effect on Windows.
+func restrictToOwner(path string) error {
+	token, err := syscall.OpenCurrentProcessToken()
+	if err != nil {
+		return fmt.Errorf("failed to open process token: %v", err)
+	}
+	defer token.Close()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %v", err)
+	}
+	sid, err := user.User.Sid.String()
+	if err != nil {
+		return fmt.Errorf("failed to get current user SID: %v", err)
+	}
+
+	sddl, err := syscall.UTF16PtrFromString("D:P(A;;FA;;;" + sid + ")")
+	if err != nil {
+		return err
+	}
+	var sd uintptr
+	if r, _, err := procConvertStringSecurityDescriptor.Call(
+		uintptr(unsafe.Pointer(sddl)), sddlRevision1, uintptr(unsafe.Pointer(&sd)), 0,
+	); r == 0 {
+		return fmt.Errorf("failed to build security descriptor: %v", err)
+	}
+	defer procLocalFree.Call(sd)
+
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procSetFileSecurity.Call(
+		uintptr(unsafe.Pointer(p)), daclSecurityInformation|protectedDaclSecurityInformation, sd,
+	); r == 0 {
+		return fmt.Errorf("failed to set ACL on %s: %v", path, err)
+	}
+	return nil
+}
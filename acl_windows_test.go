@@ -0,0 +1,83 @@
+//go:build windows
+
+package googleoauth2callback
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+var (
+	procGetFileSecurity                 = advapi32.NewProc("GetFileSecurityW")
+	procConvertSecurityDescriptorToSDDL = advapi32.NewProc("ConvertSecurityDescriptorToStringSecurityDescriptorW")
+)
+
+func fileDACL(t *testing.T, path string) string {
+	t.Helper()
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var needed uint32
+	procGetFileSecurity.Call(uintptr(unsafe.Pointer(p)), daclSecurityInformation, 0, 0, uintptr(unsafe.Pointer(&needed)))
+	if needed == 0 {
+		t.Fatalf("GetFileSecurity returned no size for %s", path)
+	}
+	sd := make([]byte, needed)
+	if r, _, err := procGetFileSecurity.Call(
+		uintptr(unsafe.Pointer(p)), daclSecurityInformation, uintptr(unsafe.Pointer(&sd[0])), uintptr(needed), uintptr(unsafe.Pointer(&needed)),
+	); r == 0 {
+		t.Fatalf("GetFileSecurity: %v", err)
+	}
+	var sddl *uint16
+	if r, _, err := procConvertSecurityDescriptorToSDDL.Call(
+		uintptr(unsafe.Pointer(&sd[0])), sddlRevision1, daclSecurityInformation, uintptr(unsafe.Pointer(&sddl)), 0,
+	); r == 0 {
+		t.Fatalf("ConvertSecurityDescriptorToStringSecurityDescriptor: %v", err)
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(sddl)))
+	n := 0
+	for *(*uint16)(unsafe.Add(unsafe.Pointer(sddl), n*2)) != 0 {
+		n++
+	}
+	return syscall.UTF16ToString(unsafe.Slice(sddl, n))
+}
+
+func currentUserSID(t *testing.T) string {
+	t.Helper()
+	token, err := syscall.OpenCurrentProcessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer token.Close()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, err := user.User.Sid.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sid
+}
+
+func TestWritePrivateFileDACL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := writePrivateFile(path, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	want := "D:P(A;;FA;;;" + currentUserSID(t) + ")"
+	if got := fileDACL(t, path); got != want {
+		t.Errorf("DACL = %s, want %s", got, want)
+	}
+
+	// Overwriting keeps the restricted DACL.
+	if err := writePrivateFile(path, []byte(`{"access_token":"x"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileDACL(t, path); got != want {
+		t.Errorf("DACL after overwrite = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type AuditRecord struct {
+	Time       time.Time
+	RemoteAddr string
+	Method     string
+	Path       string
+	StateMatch bool
+	Status     int
+	Outcome    string
+}
+
+func WithAuditSink(sink func(record AuditRecord)) Option {
+	return func(o *OAuth2Callback) {
+		o.auditSink = sink
+	}
+}
+
+var auditOutcomes = map[messageKey]string{
+	msgExchangeFailed:   "exchange_failed",
+	msgScopesNotGranted: "scopes_not_granted",
+	msgWrongAccount:     "wrong_account",
+	msgWriteTokenFailed: "save_failed",
+}
+
+type auditKey struct{}
+
+// auditRecord returns the record being built for r so handlers can fill in
+// what they found out about the request.
+func auditRecord(r *http.Request) *AuditRecord {
+	if record, ok := r.Context().Value(auditKey{}).(*AuditRecord); ok {
+		return record
+	}
+	return &AuditRecord{}
+}
+
+func (o *OAuth2Callback) auditHandler(next http.Handler) http.Handler {
+	if o.auditSink == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := &AuditRecord{
+			Time:       o.now(),
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), auditKey{}, record)))
+		record.Status = sw.status
+		if record.Outcome == "" {
+			record.Outcome = strings.ToLower(strings.ReplaceAll(http.StatusText(sw.status), " ", "_"))
+		}
+		o.auditSink(*record)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,61 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// BuildAuthURL prepares an authorization request without starting a callback
+// server, for callers that receive the redirect themselves, such as a mobile
+// bridge or a custom URL scheme handler. The request uses a fresh state and
+// PKCE verifier. complete takes the authorization code, or the whole redirected
+// URL to have its state checked, and exchanges, verifies and stores the token
+// like Authenticate does. The pending flow is also written to disk so that
+// ResumeFlow can complete it from another process.
+func (o *OAuth2Callback) BuildAuthURL() (authURL string, complete func(ctx context.Context, code string) (*oauth2.Token, error), err error) {
+	config, err := o.createOAuth2Config()
+	if err != nil {
+		return "", nil, err
+	}
+	stateToken, err := o.stateGenerator()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate state token: %v", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+	authCodeOptions := append(o.authCodeOptions(), oauth2.S256ChallengeOption(verifier))
+	var nonce string
+	if requestsOpenID(config.Scopes) {
+		if nonce, err = generateStateToken(); err != nil {
+			return "", nil, fmt.Errorf("failed to generate nonce: %v", err)
+		}
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+	authURL = config.AuthCodeURL(stateToken, authCodeOptions...)
+	if err := o.savePendingFlow(&pendingFlow{
+		State:       stateToken,
+		Verifier:    verifier,
+		Nonce:       nonce,
+		Scopes:      config.Scopes,
+		RedirectURL: config.RedirectURL,
+		CreatedAt:   o.now(),
+	}); err != nil {
+		return "", nil, fmt.Errorf("failed to save pending flow: %v", err)
+	}
+
+	complete = func(ctx context.Context, code string) (*oauth2.Token, error) {
+		code, err := o.parseManualCode(strings.TrimSpace(code), stateToken)
+		if err != nil {
+			return nil, err
+		}
+		tok, _, _, err := o.redeemCode(ctx, config, code, nonce, oauth2.VerifierOption(verifier))
+		if err != nil {
+			return nil, err
+		}
+		o.removePendingFlow(stateToken)
+		return tok, nil
+	}
+	return authURL, complete, nil
+}
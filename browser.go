@@ -0,0 +1,147 @@
+package googleoauth2callback
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+func WithOpenBrowser(open bool) Option {
+	return func(o *OAuth2Callback) {
+		o.openBrowser = open
+	}
+}
+
+// WithBrowserCommand sets the command used to open the authorization URL. A
+// "%s" in the command is replaced with the URL; otherwise the URL is appended.
+func WithBrowserCommand(command string) Option {
+	return func(o *OAuth2Callback) {
+		o.browserCommand = command
+	}
+}
+
+func browserCommand(command, url string) *exec.Cmd {
+	args := strings.Fields(command)
+	replaced := false
+	for i, arg := range args {
+		if strings.Contains(arg, "%s") {
+			args[i] = strings.ReplaceAll(arg, "%s", url)
+			replaced = true
+		}
+	}
+	if !replaced {
+		args = append(args, url)
+	}
+	return exec.Command(args[0], args[1:]...)
+}
+
+// WithAppWindow opens the authorization URL in a minimal Chrome or Edge app
+// window (--app=URL) when one of them is installed, for a dialog-like consent
+// screen instead of a full browser tab.
+func WithAppWindow(app bool) Option {
+	return func(o *OAuth2Callback) {
+		o.appWindow = app
+	}
+}
+
+var appModeBrowsers = map[string][]string{
+	"darwin": {
+		"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+		"/Applications/Chromium.app/Contents/MacOS/Chromium",
+	},
+	"windows": {
+		`C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`,
+		`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+		`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+	},
+	"linux": {
+		"google-chrome",
+		"google-chrome-stable",
+		"chromium",
+		"chromium-browser",
+		"microsoft-edge",
+	},
+}
+
+func appModeCommand(url string) *exec.Cmd {
+	for _, browser := range appModeBrowsers[runtime.GOOS] {
+		if path, err := exec.LookPath(browser); err == nil {
+			return exec.Command(path, "--app="+url)
+		}
+	}
+	return nil
+}
+
+func (o *OAuth2Callback) openURL(url string) error {
+	if strings.TrimSpace(o.browserCommand) != "" {
+		return browserCommand(o.browserCommand, url).Start()
+	}
+	if o.appWindow {
+		if cmd := appModeCommand(url); cmd != nil {
+			return cmd.Start()
+		}
+	}
+	return openBrowser(url)
+}
+
+func openBrowser(url string) error {
+	if browser := os.Getenv("BROWSER"); browser != "" {
+		return exec.Command(browser, url).Start()
+	}
+	if isWSL() {
+		if path, err := exec.LookPath("wslview"); err == nil {
+			return exec.Command(path, url).Start()
+		}
+		// cmd.exe treats & as a command separator, so it has to be escaped.
+		return exec.Command("cmd.exe", "/c", "start", "", strings.ReplaceAll(url, "&", "^&")).Start()
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+func isWSL() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	b, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(b)), "microsoft")
+}
+
+func remoteEnvironment() string {
+	switch {
+	case os.Getenv("CODESPACES") == "true":
+		return "GitHub Codespaces"
+	case os.Getenv("REMOTE_CONTAINERS") == "true":
+		return "VS Code Dev Containers"
+	case os.Getenv("VSCODE_IPC_HOOK_CLI") != "":
+		return "VS Code Remote"
+	case os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "":
+		return "SSH"
+	}
+	return ""
+}
+
+func printPortForwardHint(port string) {
+	switch env := remoteEnvironment(); env {
+	case "":
+	case "SSH":
+		fmt.Fprintf(os.Stderr, "Running over SSH: forward port %s to this machine before opening the URL, e.g. ssh -L %s:localhost:%s <host>\n", port, port, port)
+	default:
+		fmt.Fprintf(os.Stderr, "Running in %s: make sure port %s is forwarded to your local machine\n", env, port)
+	}
+}
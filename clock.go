@@ -0,0 +1,66 @@
+package googleoauth2callback
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// defaultExpiryLeeway matches the expiry delta oauth2 applies by default.
+const defaultExpiryLeeway = 10 * time.Second
+
+func WithClock(now func() time.Time) Option {
+	return func(o *OAuth2Callback) {
+		o.now = now
+	}
+}
+
+// reuseTokenSource is oauth2.ReuseTokenSourceWithExpiry with the expiry check
+// driven by the configured clock.
+type reuseTokenSource struct {
+	mu     sync.Mutex
+	tok    *oauth2.Token
+	src    oauth2.TokenSource
+	now    func() time.Time
+	leeway time.Duration
+}
+
+func (s *reuseTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.valid(s.tok) {
+		return s.tok, nil
+	}
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.tok = tok
+	return tok, nil
+}
+
+// cached returns the current token if it is still valid, without fetching a
+// new one.
+func (s *reuseTokenSource) cached() (*oauth2.Token, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tok, s.valid(s.tok)
+}
+
+// invalidate forces the next Token call to fetch a new token.
+func (s *reuseTokenSource) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tok = nil
+}
+
+func (s *reuseTokenSource) valid(tok *oauth2.Token) bool {
+	if tok == nil || tok.AccessToken == "" {
+		return false
+	}
+	if tok.Expiry.IsZero() {
+		return true
+	}
+	return s.now().Add(s.leeway).Before(tok.Expiry)
+}
@@ -0,0 +1,55 @@
+package main
+
+import "fmt"
+
+const bashCompletion = `_googleoauth2callback() {
+  local cur prev
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  prev="${COMP_WORDS[COMP_CWORD-1]}"
+  case "$prev" in
+    -config|-credentials|-token) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+    -format) COMPREPLY=($(compgen -W "adc raw env" -- "$cur")); return ;;
+    token) COMPREPLY=($(compgen -W "export" -- "$cur")); return ;;
+    completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")); return ;;
+  esac
+  if [[ "$cur" == -* ]]; then
+    COMPREPLY=($(compgen -W "-config -credentials -token -redirect-url -scopes -format" -- "$cur"))
+  else
+    COMPREPLY=($(compgen -W "status check doctor token completion" -- "$cur"))
+  fi
+}
+complete -F _googleoauth2callback googleoauth2callback
+`
+
+const zshCompletion = `#compdef googleoauth2callback
+autoload -U bashcompinit && bashcompinit
+` + bashCompletion
+
+const fishCompletion = `complete -c googleoauth2callback -f
+complete -c googleoauth2callback -n __fish_use_subcommand -a status -d 'Show the signed-in account and token'
+complete -c googleoauth2callback -n __fish_use_subcommand -a check -d 'Confirm the cached token still works'
+complete -c googleoauth2callback -n __fish_use_subcommand -a doctor -d 'Diagnose setup problems'
+complete -c googleoauth2callback -n __fish_use_subcommand -a token -d 'Export the cached token'
+complete -c googleoauth2callback -n __fish_use_subcommand -a completion -d 'Print a completion script'
+complete -c googleoauth2callback -n '__fish_seen_subcommand_from token' -a export
+complete -c googleoauth2callback -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+complete -c googleoauth2callback -o config -r -F
+complete -c googleoauth2callback -o credentials -r -F
+complete -c googleoauth2callback -o token -r -F
+complete -c googleoauth2callback -o redirect-url -r
+complete -c googleoauth2callback -o scopes -r
+complete -c googleoauth2callback -o format -r -a 'adc raw env'
+`
+
+func completion(shell string) {
+	switch shell {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		fatal(fmt.Errorf("unsupported shell: %s", shell))
+	}
+}
@@ -0,0 +1,183 @@
+// Command googleoauth2callback inspects the token cached by the
+// googleoauth2callback package and diagnoses common setup problems.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+	"github.com/yuya-takeyama/googleoauth2callback/keychainstore"
+)
+
+const usage = `Usage: googleoauth2callback [flags] <command>
+
+Commands:
+  status                  show which account is signed in, its scopes, token expiry and path
+  check                   confirm with Google that the cached token still works; exits 1 if not
+  doctor                  check the credentials file, redirect URI, callback port and network
+  token export [-format]  print the token as ADC JSON (adc), a bearer token (raw) or shell exports (env),
+                          from the token file or, with -keychain, the OS keychain
+  completion <shell>      print a completion script for bash, zsh or fish
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("googleoauth2callback", flag.ExitOnError)
+	configPath := flags.String("config", "", "path to a JSON or YAML config file")
+	credentialsPath := flags.String("credentials", "", "path to the OAuth client secret file")
+	tokenPath := flags.String("token", "", "path to the token file")
+	redirectURL := flags.String("redirect-url", "", "OAuth redirect URL")
+	scopes := flags.String("scopes", "", "comma-separated scopes")
+	keychain := flags.String("keychain", "", "read tokens from the OS keychain under this service name instead of a token file")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	args := flags.Args()[1:]
+	if flags.Arg(0) == "completion" {
+		if len(args) != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		completion(args[0])
+		return
+	}
+
+	var opts []googleoauth2callback.Option
+	if *configPath != "" {
+		loaded, err := googleoauth2callback.LoadConfig(*configPath)
+		if err != nil {
+			fatal(err)
+		}
+		opts = append(opts, loaded...)
+	}
+	if *credentialsPath != "" {
+		opts = append(opts, googleoauth2callback.WithCredentialsPath(*credentialsPath))
+	}
+	if *tokenPath != "" {
+		opts = append(opts, googleoauth2callback.WithTokenPath(*tokenPath))
+	}
+	if *redirectURL != "" {
+		opts = append(opts, googleoauth2callback.WithRedirectURL(*redirectURL))
+	}
+	if *scopes != "" {
+		opts = append(opts, googleoauth2callback.WithScopes(strings.Split(*scopes, ",")))
+	}
+	if *keychain != "" {
+		opts = append(opts, googleoauth2callback.WithTokenStore(keychainstore.New(keychainstore.WithService(*keychain))))
+	}
+	callback := googleoauth2callback.New(opts...)
+
+	ctx := context.Background()
+	switch cmd := flags.Arg(0); cmd {
+	case "status":
+		status(ctx, callback)
+	case "check":
+		check(ctx, callback)
+	case "doctor":
+		doctor(ctx, callback)
+	case "token":
+		if len(args) == 0 || args[0] != "export" {
+			flags.Usage()
+			os.Exit(2)
+		}
+		exportToken(ctx, callback, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+func status(ctx context.Context, callback *googleoauth2callback.OAuth2Callback) {
+	s, err := callback.TokenStatus(ctx)
+	if err != nil {
+		fatal(fmt.Errorf("not signed in: %v", err))
+	}
+	account := s.Account.Email
+	if account == "" {
+		account = "(unknown)"
+	}
+	fmt.Printf("Account:       %s\n", account)
+	fmt.Printf("Scopes:        %s\n", strings.Join(s.Account.Scopes, " "))
+	switch {
+	case s.Expiry.IsZero():
+		fmt.Printf("Expiry:        none\n")
+	case time.Until(s.Expiry) <= 0:
+		fmt.Printf("Expiry:        %s (expired)\n", s.Expiry.Format(time.RFC3339))
+	default:
+		fmt.Printf("Expiry:        %s (in %s)\n", s.Expiry.Format(time.RFC3339), time.Until(s.Expiry).Round(time.Second))
+	}
+	fmt.Printf("Refresh token: %t\n", s.HasRefreshToken)
+	if s.Path != "" {
+		fmt.Printf("Token path:    %s\n", s.Path)
+	}
+}
+
+func check(ctx context.Context, callback *googleoauth2callback.OAuth2Callback) {
+	health := callback.CheckToken(ctx)
+	if !health.Healthy {
+		fmt.Printf("[FAIL] %s: %v\n", health.Account.Email, health.Err)
+		os.Exit(1)
+	}
+	fmt.Printf("[ OK ] %s via %s, access token valid until %s\n", health.Account.Email, health.Method, health.Expiry.Format(time.RFC3339))
+}
+
+func doctor(ctx context.Context, callback *googleoauth2callback.OAuth2Callback) {
+	failed := false
+	for _, check := range callback.Doctor(ctx) {
+		if check.Err != nil {
+			failed = true
+			fmt.Printf("[FAIL] %s: %v\n", check.Name, check.Err)
+			continue
+		}
+		fmt.Printf("[ OK ] %s\n", check.Name)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func exportToken(ctx context.Context, callback *googleoauth2callback.OAuth2Callback, args []string) {
+	flags := flag.NewFlagSet("token export", flag.ExitOnError)
+	format := flags.String("format", "raw", "output format: adc, raw or env")
+	flags.Parse(args)
+
+	switch *format {
+	case "adc":
+		if err := callback.ExportADC(os.Stdout); err != nil {
+			fatal(err)
+		}
+	case "raw", "env":
+		tok, err := callback.AccessToken(ctx)
+		if err != nil {
+			fatal(err)
+		}
+		if *format == "raw" {
+			fmt.Println(tok.AccessToken)
+			return
+		}
+		fmt.Printf("export GOOGLE_OAUTH_ACCESS_TOKEN=%s\n", tok.AccessToken)
+		if !tok.Expiry.IsZero() {
+			fmt.Printf("export GOOGLE_OAUTH_ACCESS_TOKEN_EXPIRY=%s\n", tok.Expiry.Format(time.RFC3339))
+		}
+	default:
+		fatal(fmt.Errorf("unknown format: %s", *format))
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
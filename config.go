@@ -0,0 +1,76 @@
+package googleoauth2callback
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Config struct {
+	CredentialsPath string   `json:"credentials_path"`
+	TokenPath       string   `json:"token_path"`
+	RedirectURL     string   `json:"redirect_url"`
+	Scopes          []string `json:"scopes"`
+	Prompt          string   `json:"prompt"`
+	OpenBrowser     *bool    `json:"open_browser"`
+	Locale          string   `json:"locale"`
+}
+
+// LoadConfig reads a config file. Files ending in .yaml or .yml are parsed as
+// YAML, with the keys of the JSON format, and anything else as JSON; unknown
+// keys are an error in both.
+func LoadConfig(path string) ([]Option, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %v", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		b, err := yamlToJSON(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse config file %s: %v", path, err)
+		}
+		r = bytes.NewReader(b)
+	}
+
+	var cfg Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %v", path, err)
+	}
+	return cfg.Options(), nil
+}
+
+func (c *Config) Options() []Option {
+	var opts []Option
+	if c.CredentialsPath != "" {
+		opts = append(opts, WithCredentialsPath(c.CredentialsPath))
+	}
+	if c.TokenPath != "" {
+		opts = append(opts, WithTokenPath(c.TokenPath))
+	}
+	if c.RedirectURL != "" {
+		opts = append(opts, WithRedirectURL(c.RedirectURL))
+	}
+	if len(c.Scopes) > 0 {
+		opts = append(opts, WithScopes(c.Scopes))
+	}
+	if c.Prompt != "" {
+		opts = append(opts, WithPrompt(c.Prompt))
+	}
+	if c.OpenBrowser != nil {
+		opts = append(opts, WithOpenBrowser(*c.OpenBrowser))
+	}
+	if c.Locale != "" {
+		opts = append(opts, WithLocale(c.Locale))
+	}
+	return opts
+}
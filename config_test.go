@@ -0,0 +1,86 @@
+package googleoauth2callback
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{
+  "credentials_path": "/etc/app/credentials.json",
+  "redirect_url": "http://localhost:8080/callback",
+  "scopes": ["email", "https://www.googleapis.com/auth/drive"],
+  "open_browser": false,
+  "locale": "ja"
+}`,
+		"config.yaml": `# app settings
+credentials_path: /etc/app/credentials.json
+redirect_url: "http://localhost:8080/callback"  # loopback
+scopes:
+  - email
+  - 'https://www.googleapis.com/auth/drive'
+open_browser: false
+locale: ja
+`,
+		"config.yml": `---
+credentials_path: /etc/app/credentials.json
+redirect_url: http://localhost:8080/callback
+scopes: [email, "https://www.googleapis.com/auth/drive"]
+open_browser: false
+locale: 'ja'
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			opts, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := New(opts...)
+			if o.redirectURL != "http://localhost:8080/callback" {
+				t.Errorf("redirect URL = %q", o.redirectURL)
+			}
+			if !slices.Equal(o.scopes, []string{"email", "https://www.googleapis.com/auth/drive"}) {
+				t.Errorf("scopes = %v", o.scopes)
+			}
+			if o.openBrowser {
+				t.Error("open_browser: false was not applied")
+			}
+			if o.locale != "ja" {
+				t.Errorf("locale = %q", o.locale)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsInvalidYAML(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":    "credentials: creds.json\n",
+		"nested mapping": "credentials_path:\n  file: creds.json\n",
+		"indented key":   "prompt: consent\n  locale: ja\n",
+		"duplicate key":  "locale: ja\nlocale: en\n",
+		"wrong type":     "open_browser: sometimes\n",
+		"anchor":         "locale: &lang ja\nprompt: *lang\n",
+		"tab indent":     "scopes:\n\t- email\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadConfig(path)
+			if err == nil || !strings.Contains(err.Error(), "config.yaml") {
+				t.Errorf("LoadConfig error = %v, want a parse error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package googleoauth2callback
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yuya-takeyama/googleoauth2callback/scopes"
+)
+
+var ErrConsentDeclined = errors.New("consent declined")
+
+// WithConsentPreview asks confirm before the user is sent to the browser. The
+// flow fails with ErrConsentDeclined when it returns false.
+func WithConsentPreview(confirm func(requested []string) (bool, error)) Option {
+	return func(o *OAuth2Callback) {
+		o.consentPreview = confirm
+	}
+}
+
+// TerminalConsentPreview lists what each requested scope allows and asks for
+// confirmation on the terminal.
+func TerminalConsentPreview(requested []string) (bool, error) {
+	fmt.Fprintln(os.Stderr, "This app is requesting permission to:")
+	for _, scope := range requested {
+		if description, ok := scopes.Describe(scope); ok {
+			fmt.Fprintf(os.Stderr, "  - %s (%s)\n", description, scope)
+		} else {
+			fmt.Fprintf(os.Stderr, "  - %s\n", scope)
+		}
+	}
+	fmt.Fprint(os.Stderr, "Continue? [y/N]: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return false, fmt.Errorf("failed to read confirmation: %v", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+func (o *OAuth2Callback) confirmConsent(requested []string) error {
+	if o.consentPreview == nil {
+		return nil
+	}
+	ok, err := o.consentPreview(requested)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConsentDeclined
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// credentialsCacheEntry remembers the result of parsing the credentials file,
+// including a failure, until the file changes.
+type credentialsCacheEntry struct {
+	path    string
+	modTime time.Time
+	size    int64
+	raw     []byte
+	creds   *Credentials
+	err     error
+}
+
+func (e *credentialsCacheEntry) matches(path string, info os.FileInfo) bool {
+	return e != nil && e.path == path && e.modTime.Equal(info.ModTime()) && e.size == info.Size()
+}
+
+// ReloadCredentials re-reads the credentials file. Clients that were already
+// handed out switch to the new client secret and refresh their access token
+// with it on the next request.
+func (o *OAuth2Callback) ReloadCredentials() error {
+	o.credentialsMu.Lock()
+	o.credentialsCache = nil
+	o.credentialsMu.Unlock()
+	return o.reloadCredentials()
+}
+
+// WithCredentialsReload makes clients check the credentials file for changes
+// at most once per interval, picking up rotated client secrets without a
+// restart.
+func WithCredentialsReload(interval time.Duration) Option {
+	return func(o *OAuth2Callback) {
+		o.credentialsReloadInterval = interval
+	}
+}
+
+func (o *OAuth2Callback) reloadCredentials() error {
+	_, creds, err := o.readCredentials()
+	if err != nil {
+		return err
+	}
+
+	o.tokenSourceMu.Lock()
+	defer o.tokenSourceMu.Unlock()
+	if creds == o.loadedCredentials {
+		return nil
+	}
+	if o.swapConfig == nil {
+		// Token sources not built from an OAuth client are simply rebuilt.
+		o.sharedTokenSource = nil
+		return nil
+	}
+	config, err := o.createOAuth2Config()
+	if err != nil {
+		return err
+	}
+	o.swapConfig(config)
+	o.loadedCredentials = creds
+	o.debugf("reloaded credentials from %s", o.CredentialsLoader())
+	return nil
+}
+
+type reloadingTokenSource struct {
+	callback *OAuth2Callback
+	src      oauth2.TokenSource
+}
+
+func (s *reloadingTokenSource) Token() (*oauth2.Token, error) {
+	o := s.callback
+	o.tokenSourceMu.Lock()
+	due := o.now().Sub(o.lastCredentialsCheck) >= o.credentialsReloadInterval
+	if due {
+		o.lastCredentialsCheck = o.now()
+	}
+	o.tokenSourceMu.Unlock()
+	if due {
+		if _, ok := o.CredentialsLoader().(*fileCredentialsLoader); !ok {
+			o.credentialsMu.Lock()
+			o.credentialsCache = nil
+			o.credentialsMu.Unlock()
+		}
+		if err := o.reloadCredentials(); err != nil {
+			o.warn(fmt.Errorf("failed to reload credentials: %v", err))
+		}
+	}
+	return s.src.Token()
+}
+
+func (o *OAuth2Callback) cachedCredentials(absPath string, info os.FileInfo) ([]byte, *Credentials, error) {
+	o.credentialsMu.Lock()
+	defer o.credentialsMu.Unlock()
+	if entry := o.credentialsCache; entry.matches(absPath, info) {
+		return entry.raw, entry.creds, entry.err
+	}
+	raw, creds, err := parseCredentialsFile(absPath)
+	o.credentialsCache = &credentialsCacheEntry{
+		path:    absPath,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		raw:     raw,
+		creds:   creds,
+		err:     err,
+	}
+	return raw, creds, err
+}
+
+// loadedCredentialsJSON reads credentials from a loader other than a file
+// once and keeps the result until the cache is cleared.
+func (o *OAuth2Callback) loadedCredentialsJSON() ([]byte, *Credentials, error) {
+	o.credentialsMu.Lock()
+	defer o.credentialsMu.Unlock()
+	if entry := o.credentialsCache; entry != nil && entry.path == "" {
+		return entry.raw, entry.creds, entry.err
+	}
+	raw, err := o.CredentialsLoader().LoadCredentials(context.Background())
+	var creds *Credentials
+	if err == nil {
+		raw, creds, err = parseCredentials(raw)
+	}
+	// Failures are not cached, so a missing secret is looked up again.
+	if err != nil {
+		return nil, nil, err
+	}
+	o.credentialsCache = &credentialsCacheEntry{raw: raw, creds: creds}
+	return raw, creds, nil
+}
@@ -0,0 +1,98 @@
+package googleoauth2callback
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type CredentialsError struct {
+	Path          string
+	MissingFields []string
+	Hint          string
+}
+
+func (e *CredentialsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid credentials file %s", e.Path)
+	if len(e.MissingFields) > 0 {
+		fmt.Fprintf(&b, ": missing required fields: %s", strings.Join(e.MissingFields, ", "))
+	}
+	if e.Hint != "" {
+		fmt.Fprintf(&b, " (%s)", e.Hint)
+	}
+	return b.String()
+}
+
+// validateCredentials checks that raw holds a "Web application" client, or a
+// "Desktop app" client when customScheme is set: Google only accepts
+// private-use scheme redirects for installed applications.
+func validateCredentials(path string, raw []byte, creds *Credentials, customScheme bool) error {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &top); err != nil {
+		return fmt.Errorf("unable to parse client secret file: %v", err)
+	}
+
+	if customScheme {
+		if _, ok := top["installed"]; !ok {
+			return &CredentialsError{
+				Path:          path,
+				MissingFields: []string{"installed"},
+				Hint:          `custom URL scheme redirects need a "Desktop app" OAuth client; Google rejects private-use schemes for "Web application" clients`,
+			}
+		}
+		if creds.Installed.ClientID == "" {
+			return &CredentialsError{Path: path, MissingFields: []string{"installed.client_id"}}
+		}
+		return nil
+	}
+
+	if _, ok := top["web"]; !ok {
+		return &CredentialsError{
+			Path:          path,
+			MissingFields: []string{"web"},
+			Hint:          credentialsTypeHint(top, creds.Type),
+		}
+	}
+
+	var missing []string
+	if creds.Web.ClientID == "" {
+		missing = append(missing, "web.client_id")
+	}
+	if len(missing) > 0 {
+		return &CredentialsError{Path: path, MissingFields: missing}
+	}
+	return nil
+}
+
+// Google's published OAuth 2.0 endpoints, used when a client secret file
+// omits auth_uri or token_uri.
+const (
+	googleAuthURI  = "https://accounts.google.com/o/oauth2/auth"
+	googleTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+func applyDefaultEndpoints(creds *Credentials) {
+	if creds.Web.ClientID == "" {
+		return
+	}
+	if creds.Web.AuthURI == "" {
+		creds.Web.AuthURI = googleAuthURI
+	}
+	if creds.Web.TokenURI == "" {
+		creds.Web.TokenURI = googleTokenURI
+	}
+}
+
+func credentialsTypeHint(top map[string]json.RawMessage, typ string) string {
+	if _, ok := top["installed"]; ok {
+		return `this looks like a "Desktop app" OAuth client; create a "Web application" client instead, or use WithCustomSchemeRedirect`
+	}
+	switch typ {
+	case "service_account":
+		return "this looks like a service account key, not an OAuth client secret"
+	case "authorized_user":
+		return "this looks like gcloud application default credentials, not an OAuth client secret"
+	}
+	return `download the OAuth client JSON for a "Web application" client from Google Cloud Console`
+}
@@ -0,0 +1,79 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateCredentialsClientType(t *testing.T) {
+	const (
+		web       = `{"web":{"client_id":"cid","client_secret":"sec"}}`
+		installed = `{"installed":{"client_id":"cid","client_secret":"sec","redirect_uris":["http://localhost"]}}`
+	)
+	tests := []struct {
+		name         string
+		raw          string
+		customScheme bool
+		wantErr      bool
+	}{
+		{name: "web client", raw: web},
+		{name: "installed client", raw: installed, wantErr: true},
+		{name: "installed client with custom scheme", raw: installed, customScheme: true},
+		{name: "web client with custom scheme", raw: web, customScheme: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, creds, err := parseCredentials([]byte(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = validateCredentials("credentials.json", raw, creds, tt.customScheme)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var credsErr *CredentialsError
+			if err != nil && !errors.As(err, &credsErr) {
+				t.Errorf("error is %T, want *CredentialsError", err)
+			}
+			if creds.Web.ClientID != "cid" || creds.Web.TokenURI != googleTokenURI {
+				t.Errorf("client = %+v", creds.Web)
+			}
+		})
+	}
+}
+
+func TestValidateCustomSchemeRedirect(t *testing.T) {
+	o := New()
+	loopbackOnly := &Credentials{Web: ClientSecret{RedirectURIs: []string{"http://localhost"}}}
+	withScheme := &Credentials{Web: ClientSecret{RedirectURIs: []string{"http://localhost", "com.example.app:/oauth2redirect"}}}
+	tests := []struct {
+		name        string
+		creds       *Credentials
+		redirectURL string
+		wantErr     bool
+	}{
+		{name: "reverse domain scheme", creds: loopbackOnly, redirectURL: "com.example.app:/oauth2redirect"},
+		{name: "scheme without dot", creds: loopbackOnly, redirectURL: "myapp:/oauth2redirect", wantErr: true},
+		{name: "http", creds: loopbackOnly, redirectURL: "http://example.com/callback", wantErr: true},
+		{name: "registered", creds: withScheme, redirectURL: "com.example.app:/oauth2redirect"},
+		{name: "not registered", creds: withScheme, redirectURL: "com.example.other:/oauth2redirect", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := o.validateCustomSchemeRedirect(tt.creds, tt.redirectURL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateCustomSchemeRedirect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExternalAccountTokenSourceChecksType(t *testing.T) {
+	o := New()
+	_, err := o.externalAccountTokenSource(context.Background(), []byte(`{"installed":{"client_id":"cid","client_secret":"sec","auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token","redirect_uris":["http://localhost"]}}`))
+	if err == nil || !strings.Contains(err.Error(), "unexpected credential type") {
+		t.Errorf("error = %v, want the credential type rejected", err)
+	}
+}
@@ -0,0 +1,149 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CredentialsLoader fetches the OAuth client secret JSON. String describes
+// the source in error messages, e.g. the file path.
+type CredentialsLoader interface {
+	LoadCredentials(ctx context.Context) ([]byte, error)
+	String() string
+}
+
+// WithCredentialsLoader reads the client secret from loader instead of
+// the credentials file. Loaders other than FromFile are read once and again
+// only on ReloadCredentials or WithCredentialsReload.
+func WithCredentialsLoader(loader CredentialsLoader) Option {
+	return func(o *OAuth2Callback) {
+		o.credentialsLoader = loader
+	}
+}
+
+// CredentialsLoader returns the loader o reads its client secret from.
+func (o *OAuth2Callback) CredentialsLoader() CredentialsLoader {
+	if o.credentialsLoader != nil {
+		return o.credentialsLoader
+	}
+	return FromFile(o.credentialsPath)
+}
+
+type fileCredentialsLoader struct {
+	path string
+}
+
+// FromFile reads the client secret from a file, as WithCredentialsPath does.
+// The file is parsed again only when it changes.
+func FromFile(path string) CredentialsLoader {
+	return &fileCredentialsLoader{path: path}
+}
+
+func (l *fileCredentialsLoader) LoadCredentials(ctx context.Context) ([]byte, error) {
+	b, err := os.ReadFile(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client secret file: %v", err)
+	}
+	return b, nil
+}
+
+func (l *fileCredentialsLoader) String() string {
+	return l.path
+}
+
+type jsonCredentialsLoader struct {
+	json []byte
+}
+
+// FromJSON uses client secret JSON the program already holds, e.g. embedded
+// in the binary.
+func FromJSON(b []byte) CredentialsLoader {
+	return &jsonCredentialsLoader{json: b}
+}
+
+func (l *jsonCredentialsLoader) LoadCredentials(ctx context.Context) ([]byte, error) {
+	return l.json, nil
+}
+
+func (l *jsonCredentialsLoader) String() string {
+	return "inline JSON"
+}
+
+type envCredentialsLoader struct {
+	name string
+}
+
+// FromEnv reads the client secret JSON, or its base64 encoding, from an
+// environment variable.
+func FromEnv(name string) CredentialsLoader {
+	return &envCredentialsLoader{name: name}
+}
+
+func (l *envCredentialsLoader) LoadCredentials(ctx context.Context) ([]byte, error) {
+	v := strings.TrimSpace(os.Getenv(l.name))
+	if v == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", l.name)
+	}
+	if strings.HasPrefix(v, "{") {
+		return []byte(v), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %s is neither JSON nor base64: %v", l.name, err)
+	}
+	return b, nil
+}
+
+func (l *envCredentialsLoader) String() string {
+	return "$" + l.name
+}
+
+type secretManagerCredentialsLoader struct {
+	client *http.Client
+	name   string
+}
+
+// FromSecretManager reads the client secret from a Google Secret Manager
+// secret version such as "projects/my-project/secrets/oauth-client/versions/latest".
+// client must be authorized for the cloud-platform scope, e.g. one from
+// google.DefaultClient.
+func FromSecretManager(client *http.Client, name string) CredentialsLoader {
+	return &secretManagerCredentialsLoader{client: client, name: name}
+}
+
+func (l *secretManagerCredentialsLoader) LoadCredentials(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://secretmanager.googleapis.com/v1/"+l.name+":access", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access secret %s: %v", l.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to access secret %s: %s", l.name, resp.Status)
+	}
+	var body struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse Secret Manager response: %v", err)
+	}
+	b, err := base64.StdEncoding.DecodeString(body.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret payload: %v", err)
+	}
+	return b, nil
+}
+
+func (l *secretManagerCredentialsLoader) String() string {
+	return "secret " + l.name
+}
@@ -0,0 +1,215 @@
+package googleoauth2callback
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// WithCustomSchemeRedirect uses a private-use URI scheme redirect such as
+// "com.example.app:/oauth2redirect" instead of a loopback callback server. The
+// operating system starts a new instance of the program with the redirected
+// URL, which has to pass it on with HandleRedirect; the scheme has to be
+// registered beforehand, e.g. with RegisterURLScheme.
+func WithCustomSchemeRedirect(redirectURL string) Option {
+	return func(o *OAuth2Callback) {
+		o.redirectURL = redirectURL
+		if u, err := url.Parse(redirectURL); err == nil {
+			o.customScheme = u.Scheme
+		}
+	}
+}
+
+// validateCustomSchemeRedirect checks a private-use scheme redirect. RFC 8252
+// asks for a reverse domain name scheme, which Google enforces. Desktop app
+// client secret files usually list only loopback redirects, so the URL is
+// compared against the registered ones only when some use a custom scheme.
+func (o *OAuth2Callback) validateCustomSchemeRedirect(creds *Credentials, redirectURL string) error {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse redirect URL: %v", err)
+	}
+	if u.Scheme == "http" || u.Scheme == "https" || !strings.Contains(u.Scheme, ".") {
+		return fmt.Errorf("custom scheme redirect %s must use a reverse domain name scheme such as com.example.app:/oauth2redirect", redirectURL)
+	}
+	for _, registered := range creds.Web.RedirectURIs {
+		if r, err := url.Parse(registered); err == nil && r.Scheme != "http" && r.Scheme != "https" {
+			return o.validateRedirectURL(creds, redirectURL)
+		}
+	}
+	return nil
+}
+
+// handoffPath is where the waiting process publishes how to reach it.
+func (o *OAuth2Callback) handoffPath() string {
+	return filepath.Join(o.tokenDir(), "redirect-"+o.customScheme+".addr")
+}
+
+// HandleRedirect passes a redirected URL found in args, usually os.Args[1:],
+// to the process waiting in Authenticate. It reports whether a URL was found,
+// in which case the current process has done its job and should exit.
+func (o *OAuth2Callback) HandleRedirect(args []string) (bool, error) {
+	if o.customScheme == "" {
+		return false, nil
+	}
+	var redirected string
+	for _, arg := range args {
+		if strings.HasPrefix(strings.ToLower(arg), strings.ToLower(o.customScheme)+":") {
+			redirected = arg
+			break
+		}
+	}
+	if redirected == "" {
+		return false, nil
+	}
+	b, err := os.ReadFile(o.handoffPath())
+	if err != nil {
+		return true, fmt.Errorf("no authentication is waiting for the redirect: %v", err)
+	}
+	addr, secret, _ := strings.Cut(strings.TrimSpace(string(b)), "\n")
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return true, fmt.Errorf("failed to reach the waiting process: %v", err)
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintf(conn, "%s\n%s\n", secret, redirected); err != nil {
+		return true, fmt.Errorf("failed to pass on the redirect: %v", err)
+	}
+	return true, nil
+}
+
+func (o *OAuth2Callback) authenticateCustomScheme(ctx context.Context) (*oauth2.Token, error) {
+	_, creds, err := o.readCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.validateCustomSchemeRedirect(creds, o.redirectURL); err != nil {
+		return nil, err
+	}
+	authURL, complete, err := o.BuildAuthURL()
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the redirect handoff: %v", err)
+	}
+	defer ln.Close()
+	secret, err := generateStateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate handoff secret: %v", err)
+	}
+	path := o.handoffPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create token directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(ln.Addr().String()+"\n"+secret+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write redirect handoff file: %v", err)
+	}
+	defer os.Remove(path)
+
+	fmt.Fprintln(os.Stderr, "Authenticate this app by visiting this url:")
+	fmt.Fprintln(os.Stderr, authURL)
+	o.recordFlowTiming(func(t *FlowTimings, now time.Time) { t.URLPresentedAt = now })
+	if o.openBrowser {
+		if err := o.openURL(authURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open browser: %v\n", err)
+		}
+	}
+	o.setFlowPhase(FlowPhaseAwaitingCallback, authURL, nil)
+	if o.onReady != nil {
+		o.onReady(authURL)
+	}
+
+	redirected := make(chan string, 1)
+	go acceptHandoff(ln, secret, redirected)
+	waitCtx, cancelWait := o.waitContext(ctx)
+	defer cancelWait()
+	defer o.startReminders(waitCtx, authURL)()
+	select {
+	case u := <-redirected:
+		return complete(ctx, u)
+	case <-waitCtx.Done():
+		return nil, context.Cause(waitCtx)
+	}
+}
+
+// acceptHandoff waits for a process started by the redirect to connect and
+// send the handoff secret followed by the redirected URL.
+func acceptHandoff(ln net.Listener, secret string, redirected chan<- string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		got, _ := r.ReadString('\n')
+		u, _ := r.ReadString('\n')
+		conn.Close()
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(secret)) == 1 {
+			redirected <- strings.TrimSpace(u)
+			return
+		}
+	}
+}
+
+// RegisterURLScheme registers the running executable as the handler of the
+// given URI scheme for the current user. On macOS a scheme can only be claimed
+// by an application bundle through CFBundleURLTypes in its Info.plist, so an
+// error is returned there.
+func RegisterURLScheme(scheme string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %v", err)
+	}
+	switch runtime.GOOS {
+	case "windows":
+		key := `HKCU\Software\Classes\` + scheme
+		for _, args := range [][]string{
+			{"add", key, "/ve", "/d", "URL:" + scheme, "/f"},
+			{"add", key, "/v", "URL Protocol", "/d", "", "/f"},
+			{"add", key + `\shell\open\command`, "/ve", "/d", `"` + exe + `" "%1"`, "/f"},
+		} {
+			if out, err := exec.Command("reg", args...).CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to register URL scheme: %v: %s", err, strings.TrimSpace(string(out)))
+			}
+		}
+		return nil
+	case "darwin":
+		return fmt.Errorf("URL schemes on macOS are registered through CFBundleURLTypes in the application bundle's Info.plist")
+	default:
+		dataDir := os.Getenv("XDG_DATA_HOME")
+		if dataDir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			dataDir = filepath.Join(home, ".local", "share")
+		}
+		name := scheme + "-handler.desktop"
+		path := filepath.Join(dataDir, "applications", name)
+		entry := fmt.Sprintf("[Desktop Entry]\nType=Application\nName=%s\nExec=%q %%u\nMimeType=x-scheme-handler/%s;\nNoDisplay=true\n", scheme, exe, scheme)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(entry), 0644); err != nil {
+			return fmt.Errorf("failed to write desktop entry: %v", err)
+		}
+		if out, err := exec.Command("xdg-mime", "default", name, "x-scheme-handler/"+scheme).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to register URL scheme: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,111 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+var sensitiveParams = map[string]bool{
+	"code":          true,
+	"state":         true,
+	"code_verifier": true,
+	"client_secret": true,
+	"refresh_token": true,
+	"access_token":  true,
+	"id_token":      true,
+}
+
+func WithDebug(debug bool) Option {
+	return func(o *OAuth2Callback) {
+		o.debug = debug
+	}
+}
+
+func (o *OAuth2Callback) debugf(format string, args ...any) {
+	if !o.debug {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[googleoauth2callback] "+format+"\n", args...)
+}
+
+func redactQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := strings.Join(values[k], ",")
+		if sensitiveParams[k] {
+			v = "REDACTED"
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (o *OAuth2Callback) debugAuthURL(authURL string) {
+	if !o.debug {
+		return
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return
+	}
+	o.debugf("auth URL: %s://%s%s %s", u.Scheme, u.Host, u.Path, redactQuery(u.Query()))
+}
+
+// tokenEndpointContext returns the context passed to oauth2 for requests to
+// the token endpoint.
+func (o *OAuth2Callback) tokenEndpointContext(ctx context.Context) context.Context {
+	if o.tokenEndpointClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, o.tokenEndpointClient)
+	}
+	ctx = o.proxyContext(ctx)
+	if !o.debug && o.userAgent == "" {
+		return ctx
+	}
+	client := &http.Client{}
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && c != nil {
+		*client = *c
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if o.debug {
+		base = &debugTransport{base: base, logf: o.debugf}
+	}
+	client.Transport = o.withUserAgent(base)
+	return context.WithValue(ctx, oauth2.HTTPClient, client)
+}
+
+type debugTransport struct {
+	base http.RoundTripper
+	logf func(format string, args ...any)
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.logf("token endpoint %s %s: %v", req.Method, debugURL(req.URL), err)
+		return nil, err
+	}
+	t.logf("token endpoint %s %s: %s", req.Method, debugURL(req.URL), res.Status)
+	return res, nil
+}
+
+// debugURL formats u for the debug log without userinfo or query, either of
+// which may carry credentials.
+func debugURL(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
@@ -0,0 +1,31 @@
+package googleoauth2callback
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugTransportOmitsQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var logged []string
+	client := &http.Client{Transport: &debugTransport{
+		base: http.DefaultTransport,
+		logf: func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) },
+	}}
+	res, err := client.Get(srv.URL + "/tokeninfo?access_token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(logged) != 1 {
+		t.Fatalf("logged %d lines, want 1", len(logged))
+	}
+	if strings.Contains(logged[0], "secret") || !strings.Contains(logged[0], "/tokeninfo") {
+		t.Errorf("logged %q", logged[0])
+	}
+}
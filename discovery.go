@@ -0,0 +1,100 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// discoveryDocument holds the fields of an OpenID Connect discovery document
+// this package uses.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	RevocationEndpoint    string `json:"revocation_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// WithIssuer takes the authorization, token, userinfo and signing key
+// endpoints from the issuer's OpenID Connect discovery document instead of
+// the credentials file and Google's fixed URLs. ID tokens are then expected to
+// come from this issuer.
+func WithIssuer(issuer string) Option {
+	return func(o *OAuth2Callback) {
+		o.issuer = strings.TrimSuffix(issuer, "/")
+	}
+}
+
+// discover fetches the discovery document once and keeps it for the lifetime
+// of o.
+func (o *OAuth2Callback) discover(ctx context.Context) (*discoveryDocument, error) {
+	o.discoveryMu.Lock()
+	defer o.discoveryMu.Unlock()
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := contextClient(o.tokenEndpointContext(ctx)).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch discovery document: %s", resp.Status)
+	}
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document: %v", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != o.issuer {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", doc.Issuer, o.issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return nil, fmt.Errorf("discovery document for %s has no authorization or token endpoint", o.issuer)
+	}
+	o.discovery = &doc
+	return &doc, nil
+}
+
+// jwksURL returns where the keys that sign ID tokens are published.
+func (o *OAuth2Callback) jwksURL(ctx context.Context) (string, error) {
+	if o.issuer == "" {
+		return googleJWKSURL, nil
+	}
+	doc, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("discovery document for %s has no jwks_uri", o.issuer)
+	}
+	return doc.JWKSURI, nil
+}
+
+func (o *OAuth2Callback) idTokenIssuers() []string {
+	if o.issuer == "" {
+		return googleIssuers
+	}
+	return []string{o.issuer}
+}
+
+func (o *OAuth2Callback) userInfoURL(ctx context.Context) (string, error) {
+	if o.issuer == "" {
+		return googleUserInfoURL, nil
+	}
+	doc, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	if doc.UserinfoEndpoint == "" {
+		return "", fmt.Errorf("discovery document for %s has no userinfo_endpoint", o.issuer)
+	}
+	return doc.UserinfoEndpoint, nil
+}
@@ -0,0 +1,89 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// TokenStatus describes the cached token without starting a flow or
+// contacting Google. It only reads the token: unlike GetClient, it does not
+// delete a token that belongs to an account other than WithExpectedAccount,
+// but fails with ErrWrongAccount.
+type TokenStatus struct {
+	Account         Account
+	Path            string
+	Expiry          time.Time
+	HasRefreshToken bool
+}
+
+func (o *OAuth2Callback) TokenStatus(ctx context.Context) (*TokenStatus, error) {
+	tok, err := o.peekToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account, err := o.accountOf(tok)
+	if err != nil {
+		return nil, err
+	}
+	status := &TokenStatus{
+		Account:         account,
+		Expiry:          tok.Expiry,
+		HasRefreshToken: tok.RefreshToken != "",
+	}
+	if o.tokenStore == nil {
+		if status.Path, err = o.resolveTokenPath(); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// DoctorCheck is the outcome of one setup check. Err is nil when the check
+// passed.
+type DoctorCheck struct {
+	Name string
+	Err  error
+}
+
+// Doctor checks the most common causes of failed sign-ins: an invalid
+// credentials file, an unregistered redirect URI, a busy callback port and an
+// unreachable token endpoint. Checks that depend on a failed one are skipped.
+func (o *OAuth2Callback) Doctor(ctx context.Context) []DoctorCheck {
+	var checks []DoctorCheck
+	config, err := o.createOAuth2Config()
+	checks = append(checks, DoctorCheck{Name: "credentials " + o.CredentialsLoader().String(), Err: err})
+	if err != nil {
+		return checks
+	}
+	_, creds, err := o.readCredentials()
+	if err != nil {
+		return append(checks, DoctorCheck{Name: "credentials file", Err: err})
+	}
+	validate := o.validateRedirectURL
+	if o.customScheme != "" {
+		validate = o.validateCustomSchemeRedirect
+	}
+	checks = append(checks, DoctorCheck{
+		Name: "redirect URI " + config.RedirectURL,
+		Err:  validate(creds, config.RedirectURL),
+	})
+
+	if o.unixSocket == "" && o.customScheme == "" && !o.activatedListener {
+		host, port, _, err := o.parseRedirectURL()
+		if err == nil {
+			var listeners []net.Listener
+			listeners, err = listen(host, port)
+			for _, ln := range listeners {
+				ln.Close()
+			}
+		}
+		checks = append(checks, DoctorCheck{Name: "callback port " + port, Err: err})
+	}
+
+	checks = append(checks, DoctorCheck{
+		Name: "token endpoint " + config.Endpoint.TokenURL,
+		Err:  o.checkTokenEndpoint(ctx, config.Endpoint.TokenURL),
+	})
+	return checks
+}
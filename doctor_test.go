@@ -0,0 +1,55 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestStatusCommandsKeepWrongAccountToken(t *testing.T) {
+	o := newCachedCallback(t)
+	o.setAccountEmail("me@gmail.com")
+	if err := o.saveToken(context.Background(), cachedToken()); err != nil {
+		t.Fatal(err)
+	}
+	tokenPath, err := o.resolveTokenPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	WithExpectedAccount("ops@example.com")(o)
+
+	if _, err := o.TokenStatus(context.Background()); !errors.Is(err, ErrWrongAccount) {
+		t.Errorf("TokenStatus error = %v, want ErrWrongAccount", err)
+	}
+	if health := o.CheckToken(context.Background()); !errors.Is(health.Err, ErrWrongAccount) {
+		t.Errorf("CheckToken error = %v, want ErrWrongAccount", health.Err)
+	}
+	if _, err := os.Stat(tokenPath); err != nil {
+		t.Errorf("token was deleted: %v", err)
+	}
+}
+
+func TestTokenStatusMatchingAccount(t *testing.T) {
+	o := newCachedCallback(t)
+	o.setAccountEmail("ops@example.com")
+	if err := o.saveToken(context.Background(), cachedToken()); err != nil {
+		t.Fatal(err)
+	}
+	WithExpectedAccount("ops@example.com")(o)
+
+	s, err := o.TokenStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Account.Email != "ops@example.com" || !s.HasRefreshToken || s.Path == "" {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+func cachedToken() *oauth2.Token {
+	return &oauth2.Token{AccessToken: "at", RefreshToken: "rt", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+}
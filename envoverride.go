@@ -0,0 +1,26 @@
+package googleoauth2callback
+
+import "os"
+
+const (
+	envCredentials = "GOOGLEOAUTH2CALLBACK_CREDENTIALS"
+	envToken       = "GOOGLEOAUTH2CALLBACK_TOKEN"
+)
+
+// WithEnvOverrides lets GOOGLEOAUTH2CALLBACK_CREDENTIALS and
+// GOOGLEOAUTH2CALLBACK_TOKEN override the credentials and token paths at run
+// time, whatever other options set them to.
+func WithEnvOverrides(enabled bool) Option {
+	return func(o *OAuth2Callback) {
+		o.envOverrides = enabled
+	}
+}
+
+func (o *OAuth2Callback) applyEnvOverrides() {
+	if path := os.Getenv(envCredentials); path != "" {
+		WithCredentialsPath(path)(o)
+	}
+	if path := os.Getenv(envToken); path != "" {
+		WithTokenPath(path)(o)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// calendarCommand lists upcoming events using the shared token source.
+func calendarCommand(ctx context.Context, callback *googleoauth2callback.OAuth2Callback, args []string) error {
+	ts, err := callback.TokenSource(ctx)
+	if err != nil {
+		return err
+	}
+
+	srv, err := calendar.NewService(ctx, option.WithTokenSource(ts))
+	if err != nil {
+		return err
+	}
+
+	events, err := srv.Events.List("primary").
+		TimeMin(time.Now().Format(time.RFC3339)).
+		SingleEvents(true).
+		OrderBy("startTime").
+		MaxResults(10).
+		Do()
+	if err != nil {
+		return err
+	}
+
+	if len(events.Items) == 0 {
+		fmt.Println("No upcoming events found.")
+		return nil
+	}
+	fmt.Println("Upcoming events:")
+	for _, event := range events.Items {
+		start := event.Start.DateTime
+		if start == "" {
+			start = event.Start.Date
+		}
+		fmt.Printf("%s (%s)\n", event.Summary, start)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// driveCommand lists files using an *http.Client from GetClient.
+func driveCommand(ctx context.Context, callback *googleoauth2callback.OAuth2Callback, args []string) error {
+	client, err := callback.GetClientContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return err
+	}
+
+	files, err := srv.Files.List().
+		Fields("nextPageToken, files(id, name)").
+		PageSize(10).
+		Do()
+	if err != nil {
+		return err
+	}
+
+	if len(files.Files) == 0 {
+		fmt.Println("No files found.")
+		return nil
+	}
+	fmt.Println("Files:")
+	for _, file := range files.Files {
+		fmt.Printf("%s (%s)\n", file.Name, file.Id)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// gmailCommand lists labels using the shared token source through
+// option.WithTokenSource.
+func gmailCommand(ctx context.Context, callback *googleoauth2callback.OAuth2Callback, args []string) error {
+	ts, err := callback.TokenSource(ctx)
+	if err != nil {
+		return err
+	}
+
+	srv, err := gmail.NewService(ctx, option.WithTokenSource(ts))
+	if err != nil {
+		return err
+	}
+
+	labels, err := srv.Users.Labels.List("me").Do()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Labels:")
+	for _, label := range labels.Labels {
+		fmt.Printf("%s\n", label.Name)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Command examples shows how to use googleoauth2callback with the Google API
+// client libraries. All subcommands share one sign-in that requests every
+// scope they need, so the browser opens at most once.
+//
+//	go run . drive
+//	go run . gmail
+//	go run . sheets <spreadsheet-id>
+//	go run . calendar
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+	"github.com/yuya-takeyama/googleoauth2callback/scopes"
+)
+
+var commands = map[string]func(ctx context.Context, callback *googleoauth2callback.OAuth2Callback, args []string) error{
+	"drive":    driveCommand,
+	"gmail":    gmailCommand,
+	"sheets":   sheetsCommand,
+	"calendar": calendarCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprintln(os.Stderr, "Usage: examples drive|gmail|sheets|calendar [args]")
+		os.Exit(2)
+	}
+
+	callback := googleoauth2callback.New(
+		googleoauth2callback.WithCredentialsPath("credentials.json"),
+		googleoauth2callback.WithRedirectURL("http://localhost:4567/callback"),
+		googleoauth2callback.WithScopes([]string{
+			scopes.DriveReadonly,
+			scopes.GmailReadonly,
+			scopes.SpreadsheetsReadonly,
+			scopes.CalendarReadonly,
+		}),
+	)
+
+	if err := commands[os.Args[1]](context.Background(), callback, os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+	"github.com/yuya-takeyama/googleoauth2callback/scopes"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// sheetsCommand prints the sheet titles of a spreadsheet with a client whose
+// access tokens carry only the Sheets scope.
+func sheetsCommand(ctx context.Context, callback *googleoauth2callback.OAuth2Callback, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: examples sheets <spreadsheet-id>")
+	}
+
+	client, err := callback.ClientForScopes(ctx, scopes.SpreadsheetsReadonly)
+	if err != nil {
+		return err
+	}
+
+	srv, err := sheets.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return err
+	}
+
+	spreadsheet, err := srv.Spreadsheets.Get(args[0]).Fields("properties.title,sheets.properties.title").Do()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s:\n", spreadsheet.Properties.Title)
+	for _, sheet := range spreadsheet.Sheets {
+		fmt.Printf("  %s\n", sheet.Properties.Title)
+	}
+	return nil
+}
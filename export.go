@@ -0,0 +1,107 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	exportFormat  = "googleoauth2callback-token"
+	exportVersion = 1
+)
+
+type exportedToken struct {
+	Format  string        `json:"format"`
+	Version int           `json:"version"`
+	Token   *oauth2.Token `json:"token"`
+}
+
+func (o *OAuth2Callback) ExportToken(w io.Writer) error {
+	tok, err := o.loadToken(context.Background())
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(exportedToken{
+		Format:  exportFormat,
+		Version: exportVersion,
+		Token:   tok,
+	}); err != nil {
+		return fmt.Errorf("failed to encode exported token: %v", err)
+	}
+	return nil
+}
+
+func (o *OAuth2Callback) ImportToken(r io.Reader) error {
+	var exported exportedToken
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&exported); err != nil {
+		return fmt.Errorf("failed to decode exported token: %v", err)
+	}
+	if exported.Format != exportFormat {
+		return fmt.Errorf("unsupported token export format: %q", exported.Format)
+	}
+	if exported.Version != exportVersion {
+		return fmt.Errorf("unsupported token export version: %d", exported.Version)
+	}
+	if exported.Token == nil || (exported.Token.AccessToken == "" && exported.Token.RefreshToken == "") {
+		return fmt.Errorf("exported token contains neither an access token nor a refresh token")
+	}
+	return o.saveToken(context.Background(), exported.Token)
+}
+
+// ExportADC writes the cached token as an "authorized_user" Application
+// Default Credentials file, usable by gcloud and the Google Cloud client
+// libraries through GOOGLE_APPLICATION_CREDENTIALS.
+func (o *OAuth2Callback) ExportADC(w io.Writer) error {
+	tok, err := o.loadToken(context.Background())
+	if err != nil {
+		return err
+	}
+	if tok.RefreshToken == "" {
+		return ErrMissingRefreshToken
+	}
+	_, creds, err := o.readCredentials()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(map[string]string{
+		"type":          "authorized_user",
+		"client_id":     creds.Web.ClientID,
+		"client_secret": creds.Web.ClientSecret,
+		"refresh_token": tok.RefreshToken,
+	}); err != nil {
+		return fmt.Errorf("failed to encode credentials: %v", err)
+	}
+	return nil
+}
+
+// AccessToken returns a valid access token from the cache, refreshing it if
+// needed, but never starts a new authorization flow: without a usable cached
+// token, or once an online access token expires, it fails with
+// ErrInteractiveAuthRequired. An account selector set with WithAccountSelector
+// still runs to pick the cached account.
+func (o *OAuth2Callback) AccessToken(ctx context.Context) (*oauth2.Token, error) {
+	ts, err := o.tokenSource(withoutInteraction(ctx))
+	if err != nil {
+		return nil, err
+	}
+	o.tokenSourceMu.Lock()
+	online := o.onlineSource
+	o.tokenSourceMu.Unlock()
+	// Online access tokens are renewed by signing in again.
+	if online != nil {
+		if _, ok := online.cached(); !ok {
+			return nil, fmt.Errorf("%w: the online access token expired", ErrInteractiveAuthRequired)
+		}
+	}
+	return ts.Token()
+}
@@ -0,0 +1,82 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestAccessTokenNeverStartsFlow(t *testing.T) {
+	tests := []struct {
+		name   string
+		online bool
+		cached *oauth2.Token
+	}{
+		{name: "no cached token"},
+		{name: "expired online token", online: true, cached: &oauth2.Token{AccessToken: "at", TokenType: "Bearer", Expiry: time.Now().Add(-time.Hour)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			o := New(
+				WithCredentialsPath(writeCredentials(t, dir, "http://127.0.0.1:1/token", "http://localhost:8080/callback")),
+				WithTokenPath(filepath.Join(dir, "token.json")),
+				WithRedirectURL("http://localhost:8080/callback"),
+				WithOnlineAccess(tt.online),
+				WithReadyHook(func(string) { t.Error("an authorization flow was started") }),
+			)
+			if tt.cached != nil {
+				if err := o.saveToken(context.Background(), tt.cached); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := o.AccessToken(ctx); !errors.Is(err, ErrInteractiveAuthRequired) {
+				t.Errorf("AccessToken error = %v, want ErrInteractiveAuthRequired", err)
+			}
+		})
+	}
+}
+
+func TestAccessTokenUsesSelectedAccount(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	o := New(
+		WithCredentialsPath(writeCredentials(t, dir, "http://127.0.0.1:1/token", "http://localhost:8080/callback")),
+		WithRedirectURL("http://localhost:8080/callback"),
+		WithNoInteractive(true),
+		WithAccountSelector(func(accounts []Account) (Account, error) {
+			for _, account := range accounts {
+				if account.Key == "b" {
+					return account, nil
+				}
+			}
+			return Account{}, fmt.Errorf("account b not listed")
+		}),
+	)
+	if err := os.MkdirAll(o.tokenDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Now().Add(time.Hour).Format(time.RFC3339)
+	for _, key := range []string{"a", "b"} {
+		tok := fmt.Sprintf(`{"access_token":"at-%s","token_type":"Bearer","expiry":%q}`, key, expiry)
+		if err := os.WriteFile(filepath.Join(o.tokenDir(), "token-"+key+".json"), []byte(tok), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tok, err := o.AccessToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "at-b" {
+		t.Errorf("access token = %q, want at-b", tok.AccessToken)
+	}
+}
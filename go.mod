@@ -4,4 +4,11 @@ go 1.24.0
 
 toolchain go1.25.5
 
-require golang.org/x/oauth2 v0.34.0
+require (
+	go.opentelemetry.io/otel v1.38.0
+	go.opentelemetry.io/otel/metric v1.38.0
+	go.opentelemetry.io/otel/trace v1.38.0
+	golang.org/x/oauth2 v0.34.0
+)
+
+require cloud.google.com/go/compute/metadata v0.3.0 // indirect
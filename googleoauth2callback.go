@@ -3,33 +3,152 @@ package googleoauth2callback
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/google/downscope"
 )
 
 type Credentials struct {
-	Web struct {
-		ClientID     string   `json:"client_id"`
-		ClientSecret string   `json:"client_secret"`
-		AuthURI      string   `json:"auth_uri"`
-		TokenURI     string   `json:"token_uri"`
-		RedirectURIs []string `json:"redirect_uris"`
-	} `json:"web"`
+	Type string       `json:"type"`
+	Web  ClientSecret `json:"web"`
+	// Installed holds a "Desktop app" client, which is only accepted with
+	// WithCustomSchemeRedirect. When present it is also copied into Web.
+	Installed ClientSecret `json:"installed"`
+}
+
+type ClientSecret struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	AuthURI      string   `json:"auth_uri"`
+	TokenURI     string   `json:"token_uri"`
+	RedirectURIs []string `json:"redirect_uris"`
 }
 
 type OAuth2Callback struct {
 	redirectURL     string
+	callbackPort    int
+	callbackPath    string
 	tokenPath       string
+	tokenNamespace  string
+	appName         string
+	tokenStore      TokenStore
 	credentialsPath string
 	scopes          []string
+	locale          string
+	openBrowser     bool
+	storageMode     StorageMode
+	stateGenerator  func() (string, error)
+	stateVerifier   func(received string) error
+	callbackServer  *CallbackServer
+	debug           bool
+	onReady         func(authURL string)
+	fallbackPorts   []int
+	audience        string
+	prompt          string
+	openerOrigin    string
+	progress        bool
+	warningHandler  func(err error)
+	impersonateUser string
+	manualCodeEntry bool
+	failFast        bool
+	auditSink       func(record AuditRecord)
+	browserCommand  string
+	userAgent       string
+	preflight       bool
+	unixSocket      string
+	tunnel          Tunnel
+	middleware      []func(http.Handler) http.Handler
+	responseHeaders http.Header
+	consentPreview  func(requested []string) (bool, error)
+	apiRetries      int
+	appWindow       bool
+	accountSelector func(accounts []Account) (Account, error)
+	tokenURLParams  url.Values
+	exchangeRetries int
+	exchangeBackoff time.Duration
+	exchangeTimeout time.Duration
+	shutdownTimeout time.Duration
+	expiryLeeway    time.Duration
+	now             func() time.Time
+	tracerProvider  trace.TracerProvider
+	meterProvider   metric.MeterProvider
+	telemetry       *telemetry
+	metrics         *metrics
+	stateFilePath   string
+	statusMu        sync.Mutex
+	status          FlowStatus
+	idTokenClaims   *IDTokenClaims
+	accountEmail    string
+	expectedAccount string
+	addedEmailScope bool
+	onRefreshError  func(err error, token *oauth2.Token)
+	pruneInvalid    bool
+	onlineAccess    bool
+	callbackCIDRs   []netip.Prefix
+	customScheme    string
+	portRange       [2]int
+	noInteractive   bool
+	maxWait         time.Duration
+	tlsConfig       *tls.Config
+	envOverrides    bool
+	reminderHook    func(authURL string, remaining time.Duration)
+	issuer          string
+	discoveryMu     sync.Mutex
+	discovery       *discoveryDocument
+
+	tokenSourceMu      sync.Mutex
+	sharedTokenSource  oauth2.TokenSource
+	sharedTransport    http.RoundTripper
+	sharedClientSource oauth2.TokenSource
+	refresher          *refreshingTokenSource
+	onlineSource       *reuseTokenSource
+	scopedSources      map[string]oauth2.TokenSource
+
+	tokenEndpointClient *http.Client
+
+	proxyURL        *url.URL
+	proxyMu         sync.Mutex
+	proxyTransports map[*http.Transport]*http.Transport
+
+	credentialsMu     sync.Mutex
+	credentialsCache  *credentialsCacheEntry
+	credentialsLoader CredentialsLoader
+
+	credentialsReloadInterval time.Duration
+	loadedCredentials         *Credentials
+	swapConfig                func(config *oauth2.Config)
+	lastCredentialsCheck      time.Time
+
+	impersonateServiceAccount string
+	impersonateScopes         []string
+
+	invalidStateLimit int
+	activatedListener bool
+	reminderInterval  time.Duration
+
+	opts  []Option
+	keyed keyedCallbacks
 }
 
 type Option func(*OAuth2Callback)
@@ -40,6 +159,18 @@ func WithRedirectURL(url string) Option {
 	}
 }
 
+func WithCallbackPort(port int) Option {
+	return func(o *OAuth2Callback) {
+		o.callbackPort = port
+	}
+}
+
+func WithCallbackPath(path string) Option {
+	return func(o *OAuth2Callback) {
+		o.callbackPath = path
+	}
+}
+
 func WithTokenPath(path string) Option {
 	return func(o *OAuth2Callback) {
 		o.tokenPath = path
@@ -49,6 +180,7 @@ func WithTokenPath(path string) Option {
 func WithCredentialsPath(path string) Option {
 	return func(o *OAuth2Callback) {
 		o.credentialsPath = path
+		o.credentialsLoader = nil
 	}
 }
 
@@ -58,82 +190,439 @@ func WithScopes(scopes []string) Option {
 	}
 }
 
+func WithExchangeRetries(retries int) Option {
+	return func(o *OAuth2Callback) {
+		o.exchangeRetries = retries
+	}
+}
+
+// WithExchangeTimeout limits each token exchange attempt. Zero or a negative
+// value means no limit beyond the context passed in.
+func WithExchangeTimeout(timeout time.Duration) Option {
+	return func(o *OAuth2Callback) {
+		o.exchangeTimeout = timeout
+	}
+}
+
+// WithFailFast ends the flow on the first bad callback request instead of
+// waiting for a valid one until the context is done.
+func WithFailFast(failFast bool) Option {
+	return func(o *OAuth2Callback) {
+		o.failFast = failFast
+	}
+}
+
+func WithTokenEndpointClient(client *http.Client) Option {
+	return func(o *OAuth2Callback) {
+		o.tokenEndpointClient = client
+	}
+}
+
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *OAuth2Callback) {
+		o.shutdownTimeout = timeout
+	}
+}
+
+func WithStateGenerator(generator func() (string, error)) Option {
+	return func(o *OAuth2Callback) {
+		o.stateGenerator = generator
+	}
+}
+
+func WithStateVerifier(verifier func(received string) error) Option {
+	return func(o *OAuth2Callback) {
+		o.stateVerifier = verifier
+	}
+}
+
+func WithPrompt(prompt string) Option {
+	return func(o *OAuth2Callback) {
+		o.prompt = prompt
+	}
+}
+
+func WithAudience(audience string) Option {
+	return func(o *OAuth2Callback) {
+		o.audience = audience
+	}
+}
+
+func WithTokenURLParam(key, value string) Option {
+	return func(o *OAuth2Callback) {
+		o.tokenURLParams.Set(key, value)
+	}
+}
+
+func WithReadyHook(hook func(authURL string)) Option {
+	return func(o *OAuth2Callback) {
+		o.onReady = hook
+	}
+}
+
 func New(opts ...Option) *OAuth2Callback {
 	callback := &OAuth2Callback{
 		redirectURL:     "http://localhost:4567/callback",
-		tokenPath:       "./token.json",
 		credentialsPath: "./credentials.json",
+		appName:         "googleoauth2callback",
+		prompt:          "consent",
+		tokenURLParams:  url.Values{},
 		scopes:          []string{},
+		stateGenerator:  generateStateToken,
+		exchangeRetries: 3,
+		exchangeBackoff: 500 * time.Millisecond,
+		exchangeTimeout: 30 * time.Second,
+		shutdownTimeout: 10 * time.Second,
+		now:             time.Now,
+		metrics:         newMetrics(),
+		responseHeaders: defaultResponseHeaders(),
+
+		invalidStateLimit: defaultInvalidStateLimit,
 	}
 
+	callback.opts = opts
 	for _, opt := range opts {
 		opt(callback)
 	}
+	if callback.expectedAccount != "" {
+		callback.requireEmailScope()
+	}
+	if callback.envOverrides {
+		callback.applyEnvOverrides()
+	}
+	callback.telemetry = newTelemetry(callback.tracerProvider, callback.meterProvider)
 
 	return callback
 }
 
-func (o *OAuth2Callback) parseRedirectURL() (string, string, error) {
+func (o *OAuth2Callback) resolveRedirectURL() (string, error) {
+	if o.callbackPort == 0 && o.callbackPath == "" {
+		return o.redirectURL, nil
+	}
+
 	u, err := url.Parse(o.redirectURL)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to parse redirect URL: %v", err)
+		return "", fmt.Errorf("failed to parse redirect URL: %v", err)
 	}
-
-	port := u.Port()
-	if port == "" {
-		if u.Scheme == "https" {
-			port = "443"
-		} else {
-			port = "80"
+	if o.callbackPort != 0 {
+		if o.callbackPort < 1 || o.callbackPort > 65535 {
+			return "", fmt.Errorf("invalid callback port: %d", o.callbackPort)
 		}
+		u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(o.callbackPort))
 	}
+	if o.callbackPath != "" {
+		if !strings.HasPrefix(o.callbackPath, "/") {
+			return "", fmt.Errorf("callback path must start with '/': %s", o.callbackPath)
+		}
+		u.Path = o.callbackPath
+	}
+	return u.String(), nil
+}
 
-	return port, u.Path, nil
+func (o *OAuth2Callback) parseRedirectURL() (string, string, string, error) {
+	redirectURL, err := o.resolveRedirectURL()
+	if err != nil {
+		return "", "", "", err
+	}
+	return splitRedirectURL(redirectURL)
 }
 
 func (o *OAuth2Callback) GetClient() (*http.Client, error) {
+	return o.GetClientContext(context.Background())
+}
+
+// GetClientContext returns a client authorized with the cached token. Unless
+// ctx carries its own oauth2.HTTPClient, every returned client shares one
+// transport and token source, so it is cheap to call per request.
+func (o *OAuth2Callback) GetClientContext(ctx context.Context) (*http.Client, error) {
+	ts, err := o.tokenSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Value(oauth2.HTTPClient) != nil {
+		return o.newClient(ctx, ts), nil
+	}
+	o.tokenSourceMu.Lock()
+	defer o.tokenSourceMu.Unlock()
+	// The token source is replaced when credentials are reloaded.
+	if o.sharedTransport == nil || o.sharedClientSource != ts {
+		o.sharedTransport = o.newClient(context.Background(), ts).Transport
+		o.sharedClientSource = ts
+	}
+	return &http.Client{Transport: o.sharedTransport}, nil
+}
+
+// TokenSource returns the token source behind GetClient, for APIs that take
+// an oauth2.TokenSource, e.g. option.WithTokenSource.
+func (o *OAuth2Callback) TokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	return o.tokenSource(ctx)
+}
+
+func (o *OAuth2Callback) DownscopedClient(ctx context.Context, rules []downscope.AccessBoundaryRule) (*http.Client, error) {
+	ts, err := o.tokenSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	downscoped, err := downscope.NewTokenSource(ctx, downscope.DownscopingConfig{
+		RootSource: ts,
+		Rules:      rules,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create downscoped token source: %v", err)
+	}
+	return o.newClient(ctx, oauth2.ReuseTokenSource(nil, downscoped)), nil
+}
+
+// tokenSource returns the token source shared by every client created from o,
+// so that an expired token is refreshed only once no matter how many clients
+// are in use.
+func (o *OAuth2Callback) tokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	o.tokenSourceMu.Lock()
+	defer o.tokenSourceMu.Unlock()
+	if o.sharedTokenSource != nil {
+		return o.sharedTokenSource, nil
+	}
+	ts, err := o.newTokenSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if o.impersonateServiceAccount != "" {
+		ts = o.impersonatedTokenSource(context.WithoutCancel(ctx), ts)
+		o.refresher = nil
+	}
+	o.sharedTokenSource = ts
+	o.scopedSources = nil
+	return ts, nil
+}
+
+// newTokenSource loads the cached token, authenticating with ctx if there is
+// none. The returned source outlives ctx, so it keeps only ctx's values.
+func (o *OAuth2Callback) newTokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	// The mark left by withoutInteraction only applies to this call, not to
+	// the sources kept afterwards.
+	detached := context.WithValue(context.WithoutCancel(ctx), noInteractionKey{}, nil)
+	b, creds, err := o.readCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OAuth2 config: %w", err)
+	}
+	switch creds.Type {
+	case "external_account":
+		return o.externalAccountTokenSource(detached, b)
+	case "service_account":
+		return o.serviceAccountTokenSource(detached, b)
+	}
+	if o.impersonateUser != "" && o.impersonateServiceAccount == "" {
+		return nil, fmt.Errorf("impersonating %s requires service account credentials or WithImpersonateServiceAccount", o.impersonateUser)
+	}
+
 	config, err := o.createOAuth2Config()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create OAuth2 config: %v", err)
+		return nil, fmt.Errorf("failed to create OAuth2 config: %w", err)
 	}
 
-	tok, err := o.tokenFromFile()
+	if o.accountSelector != nil {
+		if err := o.selectAccount(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	tok, err := o.loadToken(ctx)
+	var lookupErr *accountLookupError
+	if errors.As(err, &lookupErr) {
+		return nil, err
+	}
+	if err != nil && !o.interactive(ctx) {
+		return nil, fmt.Errorf("%w: no usable cached token: %v", ErrInteractiveAuthRequired, err)
+	}
 	if err != nil {
-		if err := o.authenticate(); err != nil {
+		tok, err = o.Authenticate(ctx)
+		if err != nil {
 			return nil, fmt.Errorf("authenticate failed: %v", err)
 		}
-		tok, err = o.tokenFromFile()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read token file: %v", err)
+	}
+	if tok.RefreshToken == "" && !o.onlineAccess {
+		o.warn(o.missingRefreshTokenError())
+	}
+	o.metrics.setTokenExpiry(tok.Expiry)
+	if o.onlineAccess {
+		return o.onlineTokenSource(detached, tok), nil
+	}
+	refresher := &refreshingTokenSource{
+		ctx:                  o.tokenEndpointContext(detached),
+		config:               config,
+		refreshToken:         tok.RefreshToken,
+		previousRefreshToken: o.loadPreviousRefreshToken(ctx),
+		onRotate: func(previous string, tok *oauth2.Token) {
+			o.rotateRefreshToken(detached, previous, tok)
+		},
+		onRefresh: func(refreshToken string) {
+			o.touchToken(detached, refreshToken)
+		},
+		onError: o.handleRefreshError,
+		last:    tok,
+		logf:    o.debugf,
+	}
+	src := &instrumentedTokenSource{
+		ctx:       detached,
+		src:       refresher,
+		telemetry: o.telemetry,
+		metrics:   o.metrics,
+	}
+	leeway := o.expiryLeeway
+	if leeway <= 0 {
+		leeway = defaultExpiryLeeway
+	}
+	reuse := &reuseTokenSource{tok: tok, src: src, now: o.now, leeway: leeway}
+	o.refresher = refresher
+	o.loadedCredentials = creds
+	o.swapConfig = func(config *oauth2.Config) {
+		refresher.setConfig(config)
+		reuse.invalidate()
+	}
+	if o.credentialsReloadInterval > 0 {
+		return &reloadingTokenSource{callback: o, src: reuse}, nil
+	}
+	return reuse, nil
+}
+
+func (o *OAuth2Callback) loadToken(ctx context.Context) (*oauth2.Token, error) {
+	if o.storageMode == StorageModeNone {
+		return nil, ErrTokenNotFound
+	}
+	_, span := o.telemetry.start(ctx, "googleoauth2callback.load_token")
+	tok, err := o.readToken(ctx)
+	if err == nil && o.expectedAccount != "" {
+		tok, err = o.checkCachedAccount(ctx, tok)
+	}
+	endSpan(span, err)
+	return tok, err
+}
+
+// peekToken loads the cached token for inspection. Unlike loadToken it never
+// deletes it or contacts Google: a token recorded for an account other than
+// the expected one is reported with ErrWrongAccount and left in place.
+func (o *OAuth2Callback) peekToken(ctx context.Context) (*oauth2.Token, error) {
+	if o.storageMode == StorageModeNone {
+		return nil, ErrTokenNotFound
+	}
+	tok, err := o.readToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if email := o.currentAccountEmail(); email != "" {
+		if err := o.checkExpectedAccount(email); err != nil {
+			o.setAccountEmail("")
+			return nil, err
 		}
 	}
-	return config.Client(context.Background(), tok), nil
+	return tok, nil
+}
+
+// readToken reads the cached token from the token store or file without any
+// of the account checks loadToken does.
+func (o *OAuth2Callback) readToken(ctx context.Context) (*oauth2.Token, error) {
+	if o.tokenStore != nil {
+		return o.loadStoredToken(ctx)
+	}
+	return o.tokenFromFile()
 }
 
 func (o *OAuth2Callback) tokenFromFile() (*oauth2.Token, error) {
-	b, err := os.ReadFile(o.tokenPath)
+	tokenPath, err := o.resolveTokenPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(tokenPath)
 	if err != nil {
 		return nil, fmt.Errorf("unable to read token file: %v", err)
 	}
-	var tok oauth2.Token
-	if err := json.Unmarshal(b, &tok); err != nil {
+	file, outdated, err := decodeTokenFile(b)
+	if err != nil {
 		return nil, fmt.Errorf("unable to parse token file: %v", err)
 	}
-	return &tok, nil
+	if err := o.checkClient(file.ClientFingerprint); err != nil {
+		o.warn(err)
+		return nil, err
+	}
+	tok := file.token()
+	if file.AccountEmail != "" {
+		o.setAccountEmail(file.AccountEmail)
+	}
+	if outdated {
+		if err := o.saveToken(context.Background(), tok); err != nil {
+			o.warn(fmt.Errorf("failed to migrate token file: %v", err))
+		}
+	}
+	return tok, nil
 }
 
-func (o *OAuth2Callback) createOAuth2Config() (*oauth2.Config, error) {
-	absPath, err := filepath.Abs(o.credentialsPath)
+func (o *OAuth2Callback) readCredentials() ([]byte, *Credentials, error) {
+	file, ok := o.CredentialsLoader().(*fileCredentialsLoader)
+	if !ok {
+		return o.loadedCredentialsJSON()
+	}
+	absPath, err := filepath.Abs(file.path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		return nil, nil, fmt.Errorf("failed to get absolute path: %v", err)
 	}
-	b, err := os.ReadFile(absPath)
+	info, err := os.Stat(absPath)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read client secret file: %v", err)
+		return nil, nil, fmt.Errorf("unable to read client secret file: %v", err)
 	}
+	return o.cachedCredentials(absPath, info)
+}
+
+func parseCredentialsFile(path string) ([]byte, *Credentials, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read client secret file: %v", err)
+	}
+	return parseCredentials(b)
+}
+
+func parseCredentials(b []byte) ([]byte, *Credentials, error) {
 	var creds Credentials
 	if err := json.Unmarshal(b, &creds); err != nil {
-		return nil, fmt.Errorf("unable to parse client secret file: %v", err)
+		return nil, nil, fmt.Errorf("unable to parse client secret file: %v", err)
+	}
+	if creds.Web.ClientID == "" && creds.Installed.ClientID != "" {
+		creds.Web = creds.Installed
+	}
+	applyDefaultEndpoints(&creds)
+	return b, &creds, nil
+}
+
+// externalAccountTokenSource builds a workload identity federation token
+// source. google.CredentialsFromJSONWithParams accepts any credential type, so
+// the type is checked here rather than trusted to the caller.
+func (o *OAuth2Callback) externalAccountTokenSource(ctx context.Context, credentialsJSON []byte) (oauth2.TokenSource, error) {
+	var file struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(credentialsJSON, &file); err != nil {
+		return nil, fmt.Errorf("failed to load external account credentials: %v", err)
+	}
+	if file.Type != "external_account" {
+		return nil, fmt.Errorf("failed to load external account credentials: unexpected credential type %q", file.Type)
+	}
+	creds, err := google.CredentialsFromJSONWithParams(ctx, credentialsJSON, google.CredentialsParams{Scopes: o.scopes})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load external account credentials: %v", err)
+	}
+	return creds.TokenSource, nil
+}
+
+func (o *OAuth2Callback) createOAuth2Config() (*oauth2.Config, error) {
+	raw, creds, err := o.readCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCredentials(o.CredentialsLoader().String(), raw, creds, o.customScheme != ""); err != nil {
+		return nil, err
+	}
+	redirectURL, err := o.resolveRedirectURL()
+	if err != nil {
+		return nil, err
 	}
 	config := &oauth2.Config{
 		ClientID:     creds.Web.ClientID,
@@ -142,12 +631,29 @@ func (o *OAuth2Callback) createOAuth2Config() (*oauth2.Config, error) {
 			AuthURL:  creds.Web.AuthURI,
 			TokenURL: creds.Web.TokenURI,
 		},
-		RedirectURL: o.redirectURL,
+		RedirectURL: redirectURL,
 		Scopes:      o.scopes,
 	}
+	if o.issuer != "" {
+		doc, err := o.discover(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		config.Endpoint.AuthURL = doc.AuthorizationEndpoint
+		config.Endpoint.TokenURL = doc.TokenEndpoint
+	}
 	return config, nil
 }
 
+func (o *OAuth2Callback) validateRedirectURL(creds *Credentials, redirectURL string) error {
+	registered := creds.Web.RedirectURIs
+	if len(registered) == 0 || slices.Contains(registered, redirectURL) {
+		return nil
+	}
+	return fmt.Errorf("redirect URL %s is not registered in %s (registered redirect URIs: %s); add it to the OAuth client in Google Cloud Console or change the redirect URL",
+		redirectURL, o.CredentialsLoader(), strings.Join(registered, ", "))
+}
+
 func generateStateToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
@@ -156,103 +662,423 @@ func generateStateToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func (o *OAuth2Callback) authenticate() error {
-	port, callbackPath, err := o.parseRedirectURL()
+func (o *OAuth2Callback) verifyState(expected, received string) error {
+	if o.stateVerifier != nil {
+		return o.stateVerifier(received)
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(received)) != 1 {
+		return fmt.Errorf("invalid state token")
+	}
+	return nil
+}
+
+func (o *OAuth2Callback) exchange(ctx context.Context, config *oauth2.Config, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	backoff := o.exchangeBackoff
+	opts = append(o.exchangeOptions(), opts...)
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithCancel(o.tokenEndpointContext(ctx))
+		if o.exchangeTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(attemptCtx, o.exchangeTimeout)
+		}
+		token, err := config.Exchange(attemptCtx, code, opts...)
+		cancel()
+		if err == nil {
+			return token, nil
+		}
+		if attempt >= o.exchangeRetries || !isRetryableExchangeError(err) {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Token exchange failed, retrying in %s: %v\n", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (o *OAuth2Callback) authCodeOptions() []oauth2.AuthCodeOption {
+	opts := []oauth2.AuthCodeOption{
+		oauth2.AccessTypeOffline,
+	}
+	if o.onlineAccess {
+		opts[0] = oauth2.AccessTypeOnline
+	}
+	if o.prompt != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("prompt", o.prompt))
+	}
+	if o.audience != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("audience", o.audience))
+	}
+	return opts
+}
+
+func (o *OAuth2Callback) exchangeOptions() []oauth2.AuthCodeOption {
+	var opts []oauth2.AuthCodeOption
+	if o.audience != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("audience", o.audience))
+	}
+	for key, values := range o.tokenURLParams {
+		for _, value := range values {
+			opts = append(opts, oauth2.SetAuthURLParam(key, value))
+		}
+	}
+	return opts
+}
+
+// redeemCode exchanges the authorization code and checks and stores the
+// result. On failure it also returns the message and status to respond with.
+func (o *OAuth2Callback) redeemCode(ctx context.Context, config *oauth2.Config, code, nonce string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, messageKey, int, error) {
+	o.recordFlowTiming(func(t *FlowTimings, now time.Time) { t.CallbackReceivedAt = now })
+	exchangeCtx, exchangeSpan := o.telemetry.start(ctx, "googleoauth2callback.exchange")
+	exchangeStart := time.Now()
+	exchanged, err := o.exchange(exchangeCtx, config, code, opts...)
+	o.metrics.observeExchange(time.Since(exchangeStart))
+	endSpan(exchangeSpan, err)
+	if err != nil {
+		return nil, msgExchangeFailed, http.StatusInternalServerError, fmt.Errorf("failed to exchange token: %v", err)
+	}
+	o.recordFlowTiming(func(t *FlowTimings, now time.Time) { t.ExchangedAt = now })
+	if err := verifyGrantedScopes(config.Scopes, exchanged); err != nil {
+		return nil, msgScopesNotGranted, http.StatusForbidden, err
+	}
+	if nonce != "" {
+		claims, err := o.verifyIDToken(o.tokenEndpointContext(ctx), exchanged, config.ClientID, nonce)
+		if err != nil {
+			return nil, msgExchangeFailed, http.StatusInternalServerError, fmt.Errorf("failed to verify id_token: %v", err)
+		}
+		o.setIDTokenClaims(claims)
+	}
+	email := o.lookupAccountEmail(o.tokenEndpointContext(ctx), exchanged)
+	if err := o.checkExpectedAccount(email); err != nil {
+		return nil, msgWrongAccount, http.StatusForbidden, err
+	}
+	o.setAccountEmail(email)
+	if err := o.saveToken(ctx, exchanged); err != nil {
+		return nil, msgWriteTokenFailed, http.StatusInternalServerError, err
+	}
+	return exchanged, 0, 0, nil
+}
+
+func isRetryableExchangeError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		if retrieveErr.Response == nil {
+			return false
+		}
+		code := retrieveErr.Response.StatusCode
+		return code >= 500 || code == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (o *OAuth2Callback) saveToken(ctx context.Context, token *oauth2.Token) error {
+	if o.storageMode == StorageModeNone {
+		return nil
+	}
+	if o.onlineAccess && token.RefreshToken != "" {
+		stripped := *token
+		stripped.RefreshToken = ""
+		token = &stripped
+	}
+	if o.tokenStore != nil {
+		return o.saveStoredToken(ctx, token)
+	}
+	stored, err := o.tokenForStorage(token)
 	if err != nil {
 		return err
 	}
+	tokenJSON, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+	tokenPath, err := o.resolveTokenPath()
+	if err != nil {
+		return err
+	}
+	absTokenPath, err := filepath.Abs(tokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute token path: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(absTokenPath), 0700); err != nil {
+		return fmt.Errorf("failed to create token directory: %v", err)
+	}
+	if err := writePrivateFile(absTokenPath, tokenJSON); err != nil {
+		return fmt.Errorf("failed to write token file: %v", err)
+	}
+	return nil
+}
+
+func (o *OAuth2Callback) Authenticate(ctx context.Context) (token *oauth2.Token, err error) {
+	if o.noInteractive {
+		return nil, ErrInteractiveAuthRequired
+	}
+	if err := o.checkCallbackCIDRs(); err != nil {
+		return nil, err
+	}
+	ctx, span := o.telemetry.start(ctx, "googleoauth2callback.authenticate")
+	o.setFlowPhase(FlowPhasePending, "", nil)
+	o.setIDTokenClaims(nil)
+	defer func() {
+		o.telemetry.recordAuth(ctx, err)
+		endSpan(span, err)
+		if err != nil {
+			o.setFlowPhase(FlowPhaseFailed, "", err)
+		} else {
+			o.setFlowPhase(FlowPhaseDone, "", nil)
+		}
+	}()
+
+	if o.customScheme != "" {
+		return o.authenticateCustomScheme(ctx)
+	}
+
+	host, port, callbackPath, err := o.parseRedirectURL()
+	if err != nil {
+		return nil, err
+	}
 
 	config, err := o.createOAuth2Config()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	_, creds, err := o.readCredentials()
+	if err != nil {
+		return nil, err
 	}
 
-	done := make(chan error)
+	// done carries the outcome of the flow. token is only assigned from it
+	// below, never from the goroutines that produce it.
+	type flowResult struct {
+		token *oauth2.Token
+		err   error
+	}
+	done := make(chan flowResult, 1)
+	deliver := func(res flowResult) {
+		select {
+		case done <- res:
+		default:
+		}
+	}
 
-	stateToken, err := generateStateToken()
+	stateToken, err := o.stateGenerator()
 	if err != nil {
-		return fmt.Errorf("failed to generate state token: %v", err)
+		return nil, fmt.Errorf("failed to generate state token: %v", err)
+	}
+	authCodeOptions := o.authCodeOptions()
+	var nonce string
+	if requestsOpenID(config.Scopes) {
+		if nonce, err = generateStateToken(); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %v", err)
+		}
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+
+	redeem := func(code string) (*oauth2.Token, messageKey, int, error) {
+		return o.redeemCode(ctx, config, code, nonce)
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
-		state := r.URL.Query().Get("state")
-		if state != stateToken {
-			http.Error(w, "Invalid state token", http.StatusBadRequest)
-			done <- fmt.Errorf("invalid state token")
+	// finish ends the flow with err. Unless fail-fast is enabled, recoverable
+	// errors such as a stray request with a wrong state only produce a warning
+	// and the server keeps waiting for the real callback.
+	finish := func(err error, recoverable bool) {
+		if recoverable && !o.failFast {
+			o.warn(fmt.Errorf("ignoring callback request: %w", err))
 			return
 		}
+		deliver(flowResult{err: err})
+	}
 
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			http.Error(w, "Code not found", http.StatusBadRequest)
-			done <- fmt.Errorf("code not found in request")
+	// completed stops the flow from answering anything once a token has been
+	// obtained; the server is torn down as soon as Authenticate returns.
+	var completed atomic.Bool
+	var invalidStates atomic.Int64
+	limiter := newRateLimiter(callbackRateLimit, callbackRateWindow, o.now)
+	callback := func(w http.ResponseWriter, r *http.Request) {
+		audit := auditRecord(r)
+		if completed.Load() {
+			audit.Outcome = "already_completed"
+			http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
 			return
 		}
-		ctx := context.Background()
-		token, err := config.Exchange(ctx, code)
-		if err != nil {
-			http.Error(w, "Failed to exchange token", http.StatusInternalServerError)
-			done <- fmt.Errorf("failed to exchange token: %v", err)
+		if !o.allowedCallbackSource(r) {
+			audit.Outcome = "forbidden_source"
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			finish(fmt.Errorf("%w: %s", ErrForbiddenCallbackSource, r.RemoteAddr), true)
+			return
+		}
+		query, err := checkCallbackQuery(r.URL.RawQuery)
+		var stateErr error
+		if err == nil {
+			stateErr = o.verifyState(stateToken, query.Get("state"))
+		}
+		// Only requests without the right state count against the limit:
+		// behind loopback or a tunnel every client shares one address, and
+		// the browser's real callback must never be turned away.
+		if (err != nil || stateErr != nil) && !limiter.allow(r) {
+			audit.Outcome = "rate_limited"
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
 			return
 		}
-		tokenJSON, err := json.Marshal(token)
+		o.metrics.incCallbackRequests()
+		o.debugf("callback request: %s %s %s", r.Method, r.URL.Path, redactQuery(r.URL.Query()))
 		if err != nil {
-			http.Error(w, "Failed to serialize token", http.StatusInternalServerError)
-			done <- fmt.Errorf("failed to marshal token: %v", err)
+			audit.Outcome = "malformed_request"
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			finish(err, true)
 			return
 		}
-		absTokenPath, err := filepath.Abs(o.tokenPath)
+		if err := stateErr; err != nil {
+			o.metrics.incInvalidState()
+			audit.Outcome = "invalid_state"
+			http.Error(w, o.message(r, msgInvalidState), http.StatusBadRequest)
+			if n := invalidStates.Add(1); o.invalidStateLimit > 0 && n >= int64(o.invalidStateLimit) {
+				finish(fmt.Errorf("%w (%d attempts)", ErrTooManyInvalidStates, n), false)
+				return
+			}
+			finish(err, true)
+			return
+		}
+
+		audit.StateMatch = true
+
+		if errCode := query.Get("error"); errCode != "" {
+			audit.Outcome = "authorization_denied"
+			http.Error(w, o.message(r, msgCodeNotFound), http.StatusBadRequest)
+			finish(fmt.Errorf("authorization failed: %s", errCode), false)
+			return
+		}
+		code := query.Get("code")
+		if code == "" {
+			audit.Outcome = "code_not_found"
+			http.Error(w, o.message(r, msgCodeNotFound), http.StatusBadRequest)
+			finish(fmt.Errorf("code not found in request"), true)
+			return
+		}
+		exchanged, msg, status, err := redeem(code)
 		if err != nil {
-			http.Error(w, "Failed to get token path", http.StatusInternalServerError)
-			done <- fmt.Errorf("failed to get absolute token path: %v", err)
+			audit.Outcome = auditOutcomes[msg]
+			http.Error(w, o.message(r, msg), status)
+			finish(err, errors.Is(err, ErrScopesNotGranted))
 			return
 		}
-		if err := os.WriteFile(absTokenPath, tokenJSON, 0644); err != nil {
-			http.Error(w, "Failed to write token file", http.StatusInternalServerError)
-			done <- fmt.Errorf("failed to write token file: %v", err)
+		if !completed.CompareAndSwap(false, true) {
+			audit.Outcome = "already_completed"
+			http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
 			return
 		}
-		fmt.Fprintf(w, "Authentication successful! You can close this tab and return to the console.")
-		done <- nil
-	})
+		audit.Outcome = "success"
+		o.writeSuccess(w, r)
+		deliver(flowResult{token: exchanged})
+	}
+
+	if o.callbackServer != nil {
+		if !o.callbackServer.started() {
+			return nil, fmt.Errorf("callback server is not started")
+		}
+		unregister := o.callbackServer.register(stateToken, o.auditHandler(o.applyMiddleware(withResponseHeaders(o.responseHeaders, http.HandlerFunc(callback)))))
+		defer unregister()
+	} else if !o.manualCodeEntry {
+		listeners, err := o.listenCallback(host, &port, config)
+		if err != nil {
+			return nil, err
+		}
+		if o.tunnel != nil {
+			publicURL, err := o.tunnel.Start(ctx, port)
+			if err != nil {
+				for _, ln := range listeners {
+					ln.Close()
+				}
+				return nil, fmt.Errorf("failed to start tunnel: %v", err)
+			}
+			defer o.tunnel.Close()
+			config.RedirectURL = strings.TrimSuffix(publicURL, "/") + callbackPath
+			if host, _, _, err = splitRedirectURL(config.RedirectURL); err != nil {
+				return nil, err
+			}
+		}
 
-	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		if o.tlsConfig != nil {
+			for i, ln := range listeners {
+				listeners[i] = tls.NewListener(ln, o.tlsConfig)
+			}
+		}
+		srv := newHTTPServer(o.auditHandler(o.applyMiddleware(withResponseHeaders(o.responseHeaders, callbackGuard(host, callbackPath, http.HandlerFunc(callback))))))
+		wait := serve(srv, listeners)
+		defer shutdownServer(srv, o.shutdownTimeout, wait)
 	}
 
-	serverError := make(chan error, 1)
-	go func() {
-		fmt.Fprintf(os.Stderr, "Starting server on port %s\n", port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverError <- fmt.Errorf("ListenAndServe error: %v", err)
+	if err := o.validateRedirectURL(creds, config.RedirectURL); err != nil {
+		return nil, err
+	}
+	if o.preflight {
+		if err := o.checkTokenEndpoint(ctx, config.Endpoint.TokenURL); err != nil {
+			return nil, err
 		}
-		close(serverError)
-	}()
+	}
+	if err := o.confirmConsent(config.Scopes); err != nil {
+		return nil, err
+	}
 
-	authURL := config.AuthCodeURL(stateToken,
-		oauth2.AccessTypeOffline,
-		oauth2.ApprovalForce)
+	authURL := config.AuthCodeURL(stateToken, authCodeOptions...)
 	fmt.Fprintln(os.Stderr, "Authenticate this app by visiting this url:")
 	fmt.Fprintln(os.Stderr, authURL)
-
-	err = <-done
-
-	if err := srv.Close(); err != nil {
-		fmt.Fprintf(os.Stderr, "Server close error: %v\n", err)
+	o.recordFlowTiming(func(t *FlowTimings, now time.Time) { t.URLPresentedAt = now })
+	o.debugAuthURL(authURL)
+	if !o.manualCodeEntry {
+		printPortForwardHint(port)
+	}
+	if o.openBrowser {
+		if err := o.openURL(authURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open browser: %v\n", err)
+		}
+	}
+	o.setFlowPhase(FlowPhaseAwaitingCallback, authURL, nil)
+	if o.onReady != nil {
+		o.onReady(authURL)
 	}
 
-	ctxShutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	_, waitSpan := o.telemetry.start(ctx, "googleoauth2callback.wait_callback")
+	waitCtx, cancelWait := o.waitContext(ctx)
+	defer cancelWait()
 
-	if errShutdown := srv.Shutdown(ctxShutdown); errShutdown != nil && errShutdown != context.DeadlineExceeded {
-		fmt.Fprintf(os.Stderr, "Server shutdown error: %v\n", errShutdown)
+	if o.manualCodeEntry {
+		go func() {
+			code, err := o.readManualCode(waitCtx, stateToken)
+			if err != nil {
+				finish(err, false)
+				return
+			}
+			// The flow may have ended while waiting for input; the code
+			// must not be redeemed then.
+			if waitCtx.Err() != nil {
+				return
+			}
+			tok, _, _, err := redeem(code)
+			if err != nil {
+				finish(err, false)
+				return
+			}
+			deliver(flowResult{token: tok})
+		}()
 	}
 
-	if serverErr := <-serverError; serverErr != nil {
-		fmt.Fprintf(os.Stderr, "Server error: %v\n", serverErr)
+	stopProgress := o.startProgress()
+	stopReminders := o.startReminders(waitCtx, authURL)
+	select {
+	case res := <-done:
+		token, err = res.token, res.err
+	case <-waitCtx.Done():
+		err = context.Cause(waitCtx)
 	}
+	stopReminders()
+	stopProgress()
+	endSpan(waitSpan, err)
 
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
 }
@@ -0,0 +1,170 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newCachedCallback returns a callback whose token is already cached, so that
+// no flow or network request is needed to build clients.
+func newCachedCallback(tb testing.TB) *OAuth2Callback {
+	tb.Helper()
+	dir := tb.TempDir()
+	credentialsPath := filepath.Join(dir, "credentials.json")
+	creds := `{"web":{"client_id":"cid","client_secret":"sec","auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token","redirect_uris":["http://localhost:8080/callback"]}}`
+	if err := os.WriteFile(credentialsPath, []byte(creds), 0600); err != nil {
+		tb.Fatal(err)
+	}
+	o := New(
+		WithCredentialsPath(credentialsPath),
+		WithTokenPath(filepath.Join(dir, "token.json")),
+		WithRedirectURL("http://localhost:8080/callback"),
+		WithNoInteractive(true),
+	)
+	tok := &oauth2.Token{AccessToken: "at", RefreshToken: "rt", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	if err := o.saveToken(context.Background(), tok); err != nil {
+		tb.Fatal(err)
+	}
+	return o
+}
+
+func TestGetClientSharesTransport(t *testing.T) {
+	o := newCachedCallback(t)
+	a, err := o.GetClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := o.GetClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatal("GetClient returned the same *http.Client twice")
+	}
+	if a.Transport != b.Transport {
+		t.Error("GetClient clients do not share a transport")
+	}
+	a.Timeout = time.Second
+	if b.Timeout != 0 {
+		t.Error("setting Timeout on one client changed another")
+	}
+}
+
+func BenchmarkGetClient(b *testing.B) {
+	o := newCachedCallback(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := o.GetClientContext(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestExchangeWithoutTimeout(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"at","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer tokenServer.Close()
+	config := &oauth2.Config{ClientID: "cid", Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL}}
+
+	for _, timeout := range []time.Duration{0, -time.Second} {
+		o := New(WithExchangeTimeout(timeout), WithExchangeRetries(0))
+		tok, err := o.exchange(context.Background(), config, "code")
+		if err != nil {
+			t.Fatalf("timeout %s: %v", timeout, err)
+		}
+		if tok.AccessToken != "at" {
+			t.Errorf("timeout %s: access token = %q", timeout, tok.AccessToken)
+		}
+	}
+}
+
+// writeCredentials writes a web client secret file using tokenURL as the
+// token endpoint and returns its path.
+func writeCredentials(tb testing.TB, dir, tokenURL, redirectURL string) string {
+	tb.Helper()
+	path := filepath.Join(dir, "credentials.json")
+	creds := fmt.Sprintf(`{"web":{"client_id":"cid","client_secret":"sec","auth_uri":"%s/auth","token_uri":"%s","redirect_uris":[%q]}}`, tokenURL, tokenURL, redirectURL)
+	if err := os.WriteFile(path, []byte(creds), 0600); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func TestGetClientContextCancelsFlow(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirectURL := fmt.Sprintf("http://127.0.0.1:%d/callback", ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	o := New(
+		WithCredentialsPath(writeCredentials(t, dir, "http://127.0.0.1:1/token", redirectURL)),
+		WithTokenPath(filepath.Join(dir, "token.json")),
+		WithRedirectURL(redirectURL),
+		WithReadyHook(func(string) { cancel() }),
+	)
+	done := make(chan error, 1)
+	go func() {
+		_, err := o.GetClientContext(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("GetClientContext succeeded without a callback")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling the context did not stop the flow")
+	}
+}
+
+func TestTokenSourceOutlivesContext(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"fresh","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer tokenServer.Close()
+
+	dir := t.TempDir()
+	o := New(
+		WithCredentialsPath(writeCredentials(t, dir, tokenServer.URL, "http://localhost:8080/callback")),
+		WithTokenPath(filepath.Join(dir, "token.json")),
+		WithRedirectURL("http://localhost:8080/callback"),
+		WithNoInteractive(true),
+	)
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "rt", TokenType: "Bearer", Expiry: time.Now().Add(-time.Hour)}
+	if err := o.saveToken(context.Background(), expired); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ts, err := o.tokenSource(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("refresh after the request context ended: %v", err)
+	}
+	if tok.AccessToken != "fresh" {
+		t.Errorf("access token = %q, want fresh", tok.AccessToken)
+	}
+}
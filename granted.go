@@ -0,0 +1,33 @@
+package googleoauth2callback
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+var ErrScopesNotGranted = errors.New("scopes not granted")
+
+// Google reports these short scope names by their full URLs in the token
+// response.
+var scopeAliases = map[string]string{
+	"email":   "https://www.googleapis.com/auth/userinfo.email",
+	"profile": "https://www.googleapis.com/auth/userinfo.profile",
+}
+
+func missingScopes(requested []string, token *oauth2.Token) []string {
+	granted, ok := token.Extra("scope").(string)
+	if !ok || granted == "" {
+		return nil
+	}
+	return scopesMissingFrom(requested, strings.Fields(granted))
+}
+
+func verifyGrantedScopes(requested []string, token *oauth2.Token) error {
+	if missing := missingScopes(requested, token); len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrScopesNotGranted, strings.Join(missing, " "))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package googleoauth2callback
+
+import (
+	"net/http"
+)
+
+const defaultContentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+func defaultResponseHeaders() http.Header {
+	return http.Header{
+		"Cache-Control":           {"no-store"},
+		"Pragma":                  {"no-cache"},
+		"Referrer-Policy":         {"no-referrer"},
+		"X-Content-Type-Options":  {"nosniff"},
+		"X-Frame-Options":         {"DENY"},
+		"Content-Security-Policy": {defaultContentSecurityPolicy},
+	}
+}
+
+// WithResponseHeader sets a header on every callback response, replacing the
+// default for that header. An empty value removes the header.
+func WithResponseHeader(key, value string) Option {
+	return func(o *OAuth2Callback) {
+		if value == "" {
+			o.responseHeaders.Del(key)
+			return
+		}
+		o.responseHeaders.Set(key, value)
+	}
+}
+
+func withResponseHeaders(headers http.Header, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, values := range headers {
+			w.Header()[key] = values
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenHealth reports whether the cached credential still works. Method is
+// "refresh" when the refresh token was redeemed and "tokeninfo" when only an
+// access token was cached and Google was asked about it instead.
+type TokenHealth struct {
+	Healthy   bool
+	Method    string
+	Account   Account
+	Expiry    time.Time
+	CheckedAt time.Time
+	Err       error
+}
+
+// CheckToken confirms with Google that the cached credential is still valid,
+// without ever starting an authorization flow. A revoked or expired refresh
+// token shows up as a *RefreshError in Err. Like TokenStatus, it never deletes
+// the cached token.
+func (o *OAuth2Callback) CheckToken(ctx context.Context) *TokenHealth {
+	health := &TokenHealth{CheckedAt: o.now()}
+	tok, err := o.peekToken(ctx)
+	if err != nil {
+		health.Err = err
+		return health
+	}
+	if account, err := o.accountOf(tok); err == nil {
+		health.Account = account
+	}
+
+	if tok.RefreshToken == "" {
+		health.Method = "tokeninfo"
+		info, err := o.introspect(ctx, tok.AccessToken)
+		if err != nil {
+			health.Err = err
+			return health
+		}
+		health.Expiry = info.Expiry
+		health.Healthy = true
+		return health
+	}
+
+	health.Method = "refresh"
+	config, err := o.createOAuth2Config()
+	if err != nil {
+		health.Err = err
+		return health
+	}
+	refreshed, err := config.TokenSource(o.tokenEndpointContext(ctx), &oauth2.Token{RefreshToken: tok.RefreshToken}).Token()
+	if err != nil {
+		health.Err = newRefreshError(err)
+		return health
+	}
+	// Keep a rotated refresh token, since the old one may stop working.
+	if refreshed.RefreshToken != tok.RefreshToken {
+		if err := o.saveToken(ctx, refreshed); err != nil {
+			o.warn(fmt.Errorf("failed to save rotated refresh token: %v", err))
+		}
+	}
+	health.Expiry = refreshed.Expiry
+	health.Healthy = true
+	return health
+}
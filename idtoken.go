@@ -0,0 +1,221 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const googleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+type IDTokenClaims struct {
+	Issuer        string    `json:"iss"`
+	Subject       string    `json:"sub"`
+	Audience      []string  `json:"aud"`
+	Email         string    `json:"email,omitempty"`
+	EmailVerified bool      `json:"email_verified,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	Picture       string    `json:"picture,omitempty"`
+	HostedDomain  string    `json:"hd,omitempty"`
+	Nonce         string    `json:"nonce,omitempty"`
+	IssuedAt      time.Time `json:"iat"`
+	Expiry        time.Time `json:"exp"`
+}
+
+type rawIDTokenClaims struct {
+	Issuer        string          `json:"iss"`
+	Subject       string          `json:"sub"`
+	Audience      json.RawMessage `json:"aud"`
+	Email         string          `json:"email"`
+	EmailVerified bool            `json:"email_verified"`
+	Name          string          `json:"name"`
+	Picture       string          `json:"picture"`
+	HostedDomain  string          `json:"hd"`
+	Nonce         string          `json:"nonce"`
+	IssuedAt      int64           `json:"iat"`
+	Expiry        int64           `json:"exp"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (o *OAuth2Callback) IDTokenClaims() *IDTokenClaims {
+	o.statusMu.Lock()
+	defer o.statusMu.Unlock()
+	return o.idTokenClaims
+}
+
+func (o *OAuth2Callback) setIDTokenClaims(claims *IDTokenClaims) {
+	o.statusMu.Lock()
+	defer o.statusMu.Unlock()
+	o.idTokenClaims = claims
+}
+
+func requestsOpenID(scopes []string) bool {
+	return slices.Contains(scopes, "openid")
+}
+
+func (o *OAuth2Callback) verifyIDToken(ctx context.Context, token *oauth2.Token, clientID, nonce string) (*IDTokenClaims, error) {
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, fmt.Errorf("token response does not contain an id_token")
+	}
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id_token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("failed to decode id_token header: %v", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported id_token algorithm: %q", header.Alg)
+	}
+
+	jwksURL, err := o.jwksURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := fetchSigningKey(ctx, jwksURL, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode id_token signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("invalid id_token signature: %v", err)
+	}
+
+	var rawClaims rawIDTokenClaims
+	if err := decodeSegment(parts[1], &rawClaims); err != nil {
+		return nil, fmt.Errorf("failed to decode id_token claims: %v", err)
+	}
+	claims, err := rawClaims.claims()
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(o.idTokenIssuers(), claims.Issuer) {
+		return nil, fmt.Errorf("unexpected id_token issuer: %q", claims.Issuer)
+	}
+	if !slices.Contains(claims.Audience, clientID) {
+		return nil, fmt.Errorf("id_token was not issued for this client")
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("id_token nonce mismatch")
+	}
+	if !o.now().Before(claims.Expiry) {
+		return nil, fmt.Errorf("id_token expired at %s", claims.Expiry.Format(time.RFC3339))
+	}
+	return claims, nil
+}
+
+func (c rawIDTokenClaims) claims() (*IDTokenClaims, error) {
+	var audience []string
+	if len(c.Audience) > 0 {
+		var single string
+		if err := json.Unmarshal(c.Audience, &single); err == nil {
+			audience = []string{single}
+		} else if err := json.Unmarshal(c.Audience, &audience); err != nil {
+			return nil, fmt.Errorf("failed to decode id_token audience: %v", err)
+		}
+	}
+	return &IDTokenClaims{
+		Issuer:        c.Issuer,
+		Subject:       c.Subject,
+		Audience:      audience,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified,
+		Name:          c.Name,
+		Picture:       c.Picture,
+		HostedDomain:  c.HostedDomain,
+		Nonce:         c.Nonce,
+		IssuedAt:      time.Unix(c.IssuedAt, 0),
+		Expiry:        time.Unix(c.Expiry, 0),
+	}, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func fetchSigningKey(ctx context.Context, jwksURL, kid string) (*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := contextClient(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch signing keys: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode signing keys: %v", err)
+	}
+	for _, k := range set.Keys {
+		if k.Kid == kid && k.Kty == "RSA" {
+			return k.publicKey()
+		}
+	}
+	return nil, fmt.Errorf("no signing key found for kid %q", kid)
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key modulus: %v", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key exponent: %v", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid signing key exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func contextClient(ctx context.Context) *http.Client {
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && c != nil {
+		return c
+	}
+	return http.DefaultClient
+}
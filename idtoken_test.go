@@ -0,0 +1,137 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// signIDToken returns a compact RS256 JWT with the given header and claims.
+func signIDToken(t *testing.T, key *rsa.PrivateKey, header, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signingInput := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	now := time.Unix(1_800_000_000, 0)
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":   "https://accounts.google.com",
+			"sub":   "1234",
+			"aud":   "cid",
+			"email": "ops@example.com",
+			"nonce": "n0nce",
+			"iat":   now.Add(-time.Minute).Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		}
+	}
+	header := map[string]any{"alg": "RS256", "kid": "k1"}
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		header  map[string]any
+		modify  func(claims map[string]any)
+		tamper  func(raw string) string
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "audience list", modify: func(c map[string]any) { c["aud"] = []string{"other", "cid"} }},
+		{name: "bad signature", key: otherKey, wantErr: "invalid id_token signature"},
+		{name: "tampered claims", tamper: func(raw string) string {
+			parts := strings.Split(raw, ".")
+			claims := validClaims()
+			claims["email"] = "attacker@example.com"
+			b, _ := json.Marshal(claims)
+			parts[1] = base64.RawURLEncoding.EncodeToString(b)
+			return strings.Join(parts, ".")
+		}, wantErr: "invalid id_token signature"},
+		{name: "unsupported algorithm", header: map[string]any{"alg": "none", "kid": "k1"}, wantErr: "unsupported id_token algorithm"},
+		{name: "unknown key", header: map[string]any{"alg": "RS256", "kid": "k2"}, wantErr: "no signing key found"},
+		{name: "wrong issuer", modify: func(c map[string]any) { c["iss"] = "https://evil.example.com" }, wantErr: "unexpected id_token issuer"},
+		{name: "wrong audience", modify: func(c map[string]any) { c["aud"] = "other-client" }, wantErr: "not issued for this client"},
+		{name: "expired", modify: func(c map[string]any) { c["exp"] = now.Add(-time.Second).Unix() }, wantErr: "id_token expired"},
+		{name: "nonce mismatch", modify: func(c map[string]any) { c["nonce"] = "replayed" }, wantErr: "nonce mismatch"},
+		{name: "malformed", tamper: func(string) string { return "a.b" }, wantErr: "malformed id_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, h, claims := key, header, validClaims()
+			if tt.key != nil {
+				signer = tt.key
+			}
+			if tt.header != nil {
+				h = tt.header
+			}
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			raw := signIDToken(t, signer, h, claims)
+			if tt.tamper != nil {
+				raw = tt.tamper(raw)
+			}
+			tok := (&oauth2.Token{AccessToken: "at"}).WithExtra(map[string]any{"id_token": raw})
+
+			o := New(WithClock(func() time.Time { return now }))
+			ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: rewriteTransport{jwks.URL}})
+			got, err := o.verifyIDToken(ctx, tok, "cid", "n0nce")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Email != "ops@example.com" || got.Subject != "1234" || !got.Expiry.Equal(now.Add(time.Hour)) {
+				t.Errorf("claims = %+v", got)
+			}
+		})
+	}
+}
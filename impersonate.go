@@ -0,0 +1,185 @@
+package googleoauth2callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	iamCredentialsURL     = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/"
+	cloudPlatformScope    = "https://www.googleapis.com/auth/cloud-platform"
+	impersonationLifetime = time.Hour
+)
+
+func WithImpersonateUser(subject string) Option {
+	return func(o *OAuth2Callback) {
+		o.impersonateUser = subject
+	}
+}
+
+// WithImpersonateServiceAccount makes clients act as serviceAccount, using the
+// authenticated credentials to mint short-lived tokens for it. The
+// authenticated credentials need the cloud-platform scope; the minted tokens
+// get scopes, or cloud-platform when none are given.
+func WithImpersonateServiceAccount(serviceAccount string, scopes ...string) Option {
+	return func(o *OAuth2Callback) {
+		o.impersonateServiceAccount = serviceAccount
+		o.impersonateScopes = scopes
+	}
+}
+
+// serviceAccountTokenSource signs tokens with a service account key. With
+// WithImpersonateUser the tokens act as that Workspace user, which requires
+// domain-wide delegation to be granted to the service account.
+func (o *OAuth2Callback) serviceAccountTokenSource(ctx context.Context, credentialsJSON []byte) (oauth2.TokenSource, error) {
+	config, err := google.JWTConfigFromJSON(credentialsJSON, o.scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service account credentials: %v", err)
+	}
+	if o.impersonateServiceAccount == "" {
+		config.Subject = o.impersonateUser
+	}
+	return oauth2.ReuseTokenSource(nil, config.TokenSource(o.tokenEndpointContext(ctx))), nil
+}
+
+func (o *OAuth2Callback) impersonatedTokenSource(ctx context.Context, base oauth2.TokenSource) oauth2.TokenSource {
+	scopes := o.impersonateScopes
+	if len(scopes) == 0 {
+		scopes = []string{cloudPlatformScope}
+	}
+	return &reuseTokenSource{
+		src: &impersonatedTokenSource{
+			ctx:            ctx,
+			client:         oauth2.NewClient(ctx, base),
+			serviceAccount: o.impersonateServiceAccount,
+			scopes:         scopes,
+			subject:        o.impersonateUser,
+			tokenURL:       google.Endpoint.TokenURL,
+			now:            o.now,
+		},
+		now:    o.now,
+		leeway: defaultExpiryLeeway,
+	}
+}
+
+type impersonatedTokenSource struct {
+	ctx            context.Context
+	client         *http.Client
+	serviceAccount string
+	scopes         []string
+	subject        string
+	tokenURL       string
+	now            func() time.Time
+}
+
+func (s *impersonatedTokenSource) Token() (*oauth2.Token, error) {
+	if s.subject != "" {
+		return s.delegatedToken()
+	}
+	return s.accessToken()
+}
+
+func (s *impersonatedTokenSource) accessToken() (*oauth2.Token, error) {
+	var res struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	err := s.call("generateAccessToken", map[string]any{
+		"scope":    s.scopes,
+		"lifetime": fmt.Sprintf("%ds", int(impersonationLifetime.Seconds())),
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{
+		AccessToken: res.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      res.ExpireTime,
+	}, nil
+}
+
+// delegatedToken has the IAM Credentials API sign a domain-wide delegation
+// assertion for the subject, so no service account key is needed.
+func (s *impersonatedTokenSource) delegatedToken() (*oauth2.Token, error) {
+	now := s.now()
+	payload, err := json.Marshal(map[string]any{
+		"iss":   s.serviceAccount,
+		"sub":   s.subject,
+		"scope": strings.Join(s.scopes, " "),
+		"aud":   s.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(impersonationLifetime).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var signed struct {
+		SignedJWT string `json:"signedJwt"`
+	}
+	if err := s.call("signJwt", map[string]string{"payload": string(payload)}, &signed); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {signed.SignedJWT},
+	}
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var res struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := doJSON(contextClient(s.ctx), req, &res); err != nil {
+		return nil, fmt.Errorf("failed to exchange delegated assertion: %v", err)
+	}
+	return &oauth2.Token{
+		AccessToken: res.AccessToken,
+		TokenType:   res.TokenType,
+		Expiry:      now.Add(time.Duration(res.ExpiresIn) * time.Second),
+	}, nil
+}
+
+func (s *impersonatedTokenSource) call(method string, body, v any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := iamCredentialsURL + url.PathEscape(s.serviceAccount) + ":" + method
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := doJSON(s.client, req, v); err != nil {
+		return fmt.Errorf("failed to impersonate %s: %v", s.serviceAccount, err)
+	}
+	return nil
+}
+
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,189 @@
+// Package keychainstore keeps tokens in the operating system's credential
+// store: the login keychain on macOS, through the security command, and the
+// Secret Service (GNOME Keyring, KWallet) on Linux, through secret-tool from
+// libsecret. Like the other stores it adds no Go dependencies.
+package keychainstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+)
+
+// runFunc runs a command with stdin and returns its standard output.
+type runFunc func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error)
+
+type Store struct {
+	service string
+	goos    string
+	run     runFunc
+}
+
+type Option func(*Store)
+
+// WithService sets the service name the tokens are filed under, which is
+// also what keychain tools show. It defaults to "googleoauth2callback".
+func WithService(service string) Option {
+	return func(s *Store) {
+		s.service = service
+	}
+}
+
+func New(opts ...Option) *Store {
+	store := &Store{
+		service: "googleoauth2callback",
+		goos:    runtime.GOOS,
+		run:     runCommand,
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	return store
+}
+
+func (s *Store) Load(ctx context.Context, key string) (*googleoauth2callback.StoredToken, error) {
+	var out []byte
+	var err error
+	switch s.goos {
+	case "darwin":
+		out, err = s.run(ctx, nil, "security", "find-generic-password", "-s", s.service, "-a", key, "-w")
+	case "linux":
+		out, err = s.run(ctx, nil, "secret-tool", "lookup", "service", s.service, "account", key)
+	default:
+		return nil, s.unsupported()
+	}
+	if err != nil {
+		if s.notFound(err) {
+			return nil, googleoauth2callback.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to read token from keychain: %v", err)
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, googleoauth2callback.ErrTokenNotFound
+	}
+
+	var stored googleoauth2callback.StoredToken
+	if err := json.Unmarshal(out, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse token from keychain: %v", err)
+	}
+	if stored.Token == nil {
+		return nil, googleoauth2callback.ErrTokenNotFound
+	}
+	return &stored, nil
+}
+
+func (s *Store) Save(ctx context.Context, stored *googleoauth2callback.StoredToken) error {
+	payload, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+	switch s.goos {
+	case "darwin":
+		// security reads the command from stdin in interactive mode, so the
+		// token never shows up in the process list.
+		if err := checkQuotable(s.service, stored.Key); err != nil {
+			return err
+		}
+		cmd := fmt.Sprintf("add-generic-password -U -s '%s' -a '%s' -X %s\n", s.service, stored.Key, hex.EncodeToString(payload))
+		_, err = s.run(ctx, []byte(cmd), "security", "-i")
+	case "linux":
+		label := s.service + " " + stored.Key
+		_, err = s.run(ctx, payload, "secret-tool", "store", "--label="+label, "service", s.service, "account", stored.Key)
+	default:
+		return s.unsupported()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write token to keychain: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	var err error
+	switch s.goos {
+	case "darwin":
+		_, err = s.run(ctx, nil, "security", "delete-generic-password", "-s", s.service, "-a", key)
+	case "linux":
+		_, err = s.run(ctx, nil, "secret-tool", "clear", "service", s.service, "account", key)
+	default:
+		return s.unsupported()
+	}
+	if err != nil && !s.notFound(err) {
+		return fmt.Errorf("failed to delete token from keychain: %v", err)
+	}
+	return nil
+}
+
+// notFound reports whether err is how the keychain tool says there is no such
+// item: security exits with 44, secret-tool with 1 and no message.
+func (s *Store) notFound(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	switch s.goos {
+	case "darwin":
+		return exitErr.ExitCode() == 44
+	case "linux":
+		return exitErr.ExitCode() == 1 && len(bytes.TrimSpace(exitErr.Stderr)) == 0
+	}
+	return false
+}
+
+func (s *Store) unsupported() error {
+	return fmt.Errorf("keychain storage is not supported on %s", s.goos)
+}
+
+// checkQuotable rejects values that cannot be passed as quoted arguments in
+// security's interactive mode.
+func checkQuotable(values ...string) error {
+	for _, v := range values {
+		if strings.ContainsAny(v, "'\\\n") {
+			return fmt.Errorf("keychain service and key must not contain single quotes, backslashes or newlines: %q", v)
+		}
+	}
+	return nil
+}
+
+func runCommand(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, &commandError{err: exitErr, msg: msg}
+		}
+	}
+	return out, err
+}
+
+// commandError adds the tool's message to its exit status.
+type commandError struct {
+	err *exec.ExitError
+	msg string
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("%v: %s", e.err, e.msg)
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
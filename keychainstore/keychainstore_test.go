@@ -0,0 +1,128 @@
+//go:build !windows
+
+package keychainstore
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+	"golang.org/x/oauth2"
+)
+
+// fakeKeychain stands in for security and secret-tool, keeping items in a map.
+type fakeKeychain struct {
+	items map[string]string
+	calls []string
+}
+
+func exitStatus(t *testing.T, code string) error {
+	t.Helper()
+	err := exec.Command("sh", "-c", "exit "+code).Run()
+	if err == nil {
+		t.Fatal("exit status command succeeded")
+	}
+	return err
+}
+
+func newFakeStore(t *testing.T, goos string) (*Store, *fakeKeychain) {
+	fake := &fakeKeychain{items: make(map[string]string)}
+	store := New(WithService("svc"))
+	store.goos = goos
+	store.run = func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		call := name + " " + strings.Join(args, " ")
+		fake.calls = append(fake.calls, call)
+		missing := exitStatus(t, "1")
+		if goos == "darwin" {
+			missing = exitStatus(t, "44")
+		}
+		switch {
+		case call == "security -i":
+			// add-generic-password -U -s 'svc' -a 'key' -X hex
+			f := strings.Fields(string(stdin))
+			b, err := hex.DecodeString(f[7])
+			if err != nil {
+				t.Fatalf("payload is not hex: %v", err)
+			}
+			fake.items[strings.Trim(f[5], "'")] = string(b)
+			return nil, nil
+		case args[0] == "store":
+			fake.items[account(args)] = string(stdin)
+			return nil, nil
+		case args[0] == "find-generic-password" || args[0] == "lookup":
+			v, ok := fake.items[account(args)]
+			if !ok {
+				return nil, missing
+			}
+			return []byte(v + "\n"), nil
+		case args[0] == "delete-generic-password" || args[0] == "clear":
+			key := account(args)
+			if _, ok := fake.items[key]; !ok {
+				return nil, missing
+			}
+			delete(fake.items, key)
+			return nil, nil
+		}
+		t.Fatalf("unexpected command %q", call)
+		return nil, nil
+	}
+	return store, fake
+}
+
+// account returns the item's account from security's -a flag or
+// secret-tool's account attribute.
+func account(args []string) string {
+	for i, arg := range args[:len(args)-1] {
+		if arg == "-a" || arg == "account" {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func TestStore(t *testing.T) {
+	for _, goos := range []string{"darwin", "linux"} {
+		t.Run(goos, func(t *testing.T) {
+			store, fake := newFakeStore(t, goos)
+			ctx := context.Background()
+
+			if _, err := store.Load(ctx, "key"); !errors.Is(err, googleoauth2callback.ErrTokenNotFound) {
+				t.Fatalf("Load of a missing item = %v, want ErrTokenNotFound", err)
+			}
+			saved := &googleoauth2callback.StoredToken{Key: "key", Token: &oauth2.Token{AccessToken: "at", RefreshToken: "rt"}}
+			if err := store.Save(ctx, saved); err != nil {
+				t.Fatal(err)
+			}
+			for _, call := range fake.calls {
+				if strings.Contains(call, "rt") {
+					t.Errorf("token passed on the command line: %q", call)
+				}
+			}
+			loaded, err := store.Load(ctx, "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loaded.Token.RefreshToken != "rt" {
+				t.Errorf("loaded refresh token = %q, want rt", loaded.Token.RefreshToken)
+			}
+			if err := store.Delete(ctx, "key"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete(ctx, "key"); err != nil {
+				t.Errorf("Delete of a missing item = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestStoreUnsupported(t *testing.T) {
+	store := New()
+	store.goos = "plan9"
+	if _, err := store.Load(context.Background(), "key"); err == nil {
+		t.Error("Load succeeded on an unsupported platform")
+	}
+}
@@ -0,0 +1,80 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// WithKeyedTokenCache bounds how many per-key token sources ClientForKey keeps
+// in memory; the least recently created one is dropped first. Zero keeps all.
+func WithKeyedTokenCache(size int) Option {
+	return func(o *OAuth2Callback) {
+		o.keyed.size = size
+	}
+}
+
+// keyedCallbacks holds one OAuth2Callback per caller-provided key, each
+// storing its token under the key as token namespace.
+type keyedCallbacks struct {
+	mu        sync.Mutex
+	size      int
+	callbacks map[string]*OAuth2Callback
+	order     []string
+}
+
+func (o *OAuth2Callback) forKey(key string) *OAuth2Callback {
+	o.keyed.mu.Lock()
+	defer o.keyed.mu.Unlock()
+	if c, ok := o.keyed.callbacks[key]; ok {
+		return c
+	}
+	if o.keyed.callbacks == nil {
+		o.keyed.callbacks = make(map[string]*OAuth2Callback)
+	}
+	namespace := keyNamespace(key)
+	opts := append(slices.Clone(o.opts), WithTokenNamespace(namespace))
+	if o.tokenPath != "" {
+		opts = append(opts, WithTokenPath(keyTokenPath(o.tokenPath, namespace)))
+	}
+	c := New(opts...)
+	c.metrics = o.metrics
+	o.keyed.callbacks[key] = c
+	o.keyed.order = append(o.keyed.order, key)
+	if o.keyed.size > 0 && len(o.keyed.order) > o.keyed.size {
+		delete(o.keyed.callbacks, o.keyed.order[0])
+		o.keyed.order = o.keyed.order[1:]
+	}
+	return c
+}
+
+// keyNamespace turns a caller-provided key into a token namespace. Keys are
+// hashed because they end up in file names and may contain anything.
+func keyNamespace(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:])[:32]
+}
+
+// keyTokenPath derives a per-key token file next to the configured one, e.g.
+// token.json becomes token-key-<hash>.json.
+func keyTokenPath(tokenPath, namespace string) string {
+	ext := filepath.Ext(tokenPath)
+	return strings.TrimSuffix(tokenPath, ext) + "-" + namespace + ext
+}
+
+// TokenSourceForKey returns the token source for the identity identified by
+// key, such as a user ID, so one OAuth2Callback can serve many identities.
+func (o *OAuth2Callback) TokenSourceForKey(ctx context.Context, key string) (oauth2.TokenSource, error) {
+	return o.forKey(key).tokenSource(ctx)
+}
+
+func (o *OAuth2Callback) ClientForKey(ctx context.Context, key string) (*http.Client, error) {
+	return o.forKey(key).GetClientContext(ctx)
+}
@@ -0,0 +1,37 @@
+package googleoauth2callback
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForKeyTokenPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	o := New(WithTokenPath(filepath.Join(dir, "token.json")))
+	a, err := o.forKey("alice").resolveTokenPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := o.forKey("bob").resolveTokenPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || a == filepath.Join(dir, "token.json") {
+		t.Errorf("keys share a token path: %s, %s", a, b)
+	}
+
+	o = New()
+	for _, key := range []string{"../../etc/passwd", "a/b", `..\x`} {
+		path, err := o.forKey(key).resolveTokenPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Dir(path) != o.tokenDir() || strings.Contains(filepath.Base(path), "..") {
+			t.Errorf("key %q escapes the token directory: %s", key, path)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package googleoauth2callback
+
+import "net/http"
+
+// LazyClient returns a client that loads the token, authenticating if
+// necessary, on its first request instead of up front. Errors surface from
+// the request that triggered them.
+func (o *OAuth2Callback) LazyClient() *http.Client {
+	return &http.Client{Transport: &lazyTransport{callback: o}}
+}
+
+type lazyTransport struct {
+	callback *OAuth2Callback
+}
+
+// RoundTrip sends req through the same transport GetClientContext would build
+// for req's context, so the proxy, retry and user agent settings apply.
+func (t *lazyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ts, err := t.callback.tokenSource(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.callback.newClient(req.Context(), ts).Transport.RoundTrip(req)
+}
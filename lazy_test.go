@@ -0,0 +1,34 @@
+package googleoauth2callback
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestLazyClientUsesProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		if got := r.Header.Get("Authorization"); got != "Bearer at" {
+			t.Errorf("Authorization = %q, want Bearer at", got)
+		}
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := newCachedCallback(t)
+	WithProxyURL(proxyURL)(o)
+	res, err := o.LazyClient().Get("http://api.example.invalid/v1/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(proxied) != 1 || proxied[0] != "http://api.example.invalid/v1/files" {
+		t.Errorf("proxied requests = %v, want the API request", proxied)
+	}
+}
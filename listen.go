@@ -0,0 +1,220 @@
+package googleoauth2callback
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"golang.org/x/oauth2"
+)
+
+var ErrPortInUse = errors.New("port already in use")
+
+func WithFallbackPorts(ports ...int) Option {
+	return func(o *OAuth2Callback) {
+		o.fallbackPorts = ports
+	}
+}
+
+// WithPortRange restricts the callback server to ports from min to max. The
+// redirect URL's port and fallback ports are used only when they fall in the
+// range; after them every port in the range is tried in turn.
+func WithPortRange(min, max int) Option {
+	return func(o *OAuth2Callback) {
+		o.portRange = [2]int{min, max}
+	}
+}
+
+func (o *OAuth2Callback) inPortRange(port int) bool {
+	if o.portRange == [2]int{} {
+		return true
+	}
+	return port >= o.portRange[0] && port <= o.portRange[1]
+}
+
+func listenAddrs(host, port string) []string {
+	switch host {
+	case "localhost":
+		return []string{
+			net.JoinHostPort("127.0.0.1", port),
+			net.JoinHostPort("::1", port),
+		}
+	case "":
+		return []string{":" + port}
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return []string{net.JoinHostPort(host, port)}
+	}
+	return []string{":" + port}
+}
+
+// listen binds every address for the redirect host. Binding succeeds as long
+// as one address is usable, since e.g. ::1 is unavailable when IPv6 is disabled.
+func listen(host, port string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	var errs []error
+	for _, addr := range listenAddrs(host, port) {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		listeners = append(listeners, ln)
+	}
+	if len(listeners) == 0 {
+		err := errors.Join(errs...)
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return nil, fmt.Errorf("failed to listen on port %s: %w: %v", port, ErrPortInUse, err)
+		}
+		return nil, fmt.Errorf("failed to listen on port %s: %v", port, err)
+	}
+	return listeners, nil
+}
+
+// listenWithFallback binds port, or the first free fallback port when it is
+// busy, and returns the listeners together with the port actually bound.
+func (o *OAuth2Callback) listenWithFallback(host, port string) ([]net.Listener, string, error) {
+	var candidates []string
+	if p, err := strconv.Atoi(port); err != nil || o.inPortRange(p) {
+		candidates = append(candidates, port)
+	}
+	for _, p := range o.fallbackPorts {
+		if o.inPortRange(p) {
+			candidates = append(candidates, strconv.Itoa(p))
+		}
+	}
+
+	var err error
+	for _, candidate := range candidates {
+		var listeners []net.Listener
+		listeners, err = listen(host, candidate)
+		if err == nil {
+			return listeners, candidate, nil
+		}
+		if !errors.Is(err, ErrPortInUse) {
+			return nil, "", err
+		}
+		if len(candidates) > 1 || o.portRange != [2]int{} {
+			fmt.Fprintf(os.Stderr, "Port %s is already in use\n", candidate)
+		}
+	}
+	if o.portRange == [2]int{} {
+		return nil, "", err
+	}
+
+	min, max := o.portRange[0], o.portRange[1]
+	if min < 1 || max > 65535 || min > max {
+		return nil, "", fmt.Errorf("invalid port range: %d-%d", min, max)
+	}
+	for p := min; p <= max; p++ {
+		candidate := strconv.Itoa(p)
+		listeners, err := listen(host, candidate)
+		if err == nil {
+			return listeners, candidate, nil
+		}
+		if !errors.Is(err, ErrPortInUse) {
+			return nil, "", err
+		}
+	}
+	return nil, "", fmt.Errorf("no free port in the allowed range %d-%d: %w", min, max, ErrPortInUse)
+}
+
+func replacePort(rawURL, port string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse redirect URL: %v", err)
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), port)
+	return u.String(), nil
+}
+
+// WithUnixSocket serves the callback on a Unix domain socket, for setups where
+// a local reverse proxy receives the redirect. The redirect URL should point at
+// the proxy.
+func WithUnixSocket(path string) Option {
+	return func(o *OAuth2Callback) {
+		o.unixSocket = path
+	}
+}
+
+// listenCallback opens the listeners for a flow's own callback server. When a
+// fallback port is used, port and the config's redirect URL are updated.
+func (o *OAuth2Callback) listenCallback(host string, port *string, config *oauth2.Config) ([]net.Listener, error) {
+	if o.activatedListener {
+		return activatedListeners()
+	}
+	if o.unixSocket != "" {
+		ln, err := listenUnix(o.unixSocket)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{ln}, nil
+	}
+
+	listeners, boundPort, err := o.listenWithFallback(host, *port)
+	if err != nil {
+		return nil, err
+	}
+	if boundPort != *port {
+		*port = boundPort
+		if config.RedirectURL, err = replacePort(config.RedirectURL, boundPort); err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, err
+		}
+	}
+	return listeners, nil
+}
+
+// listenUnix removes a socket left behind by an earlier run before listening.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %v", path, err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	return ln, nil
+}
+
+// limitListener caps the number of simultaneously open connections, so that a
+// scanner holding connections open cannot starve the browser.
+type limitListener struct {
+	net.Listener
+	sem chan struct{}
+}
+
+func newLimitListener(ln net.Listener, n int) net.Listener {
+	return &limitListener{Listener: ln, sem: make(chan struct{}, n)}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	l.sem <- struct{}{}
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	return &limitConn{Conn: conn, release: func() { <-l.sem }}, nil
+}
+
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
@@ -0,0 +1,113 @@
+package googleoauth2callback
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	localCertFile = "localhost.pem"
+	localKeyFile  = "localhost-key.pem"
+)
+
+// WithTLSConfig serves the callback over HTTPS, for OAuth clients that only
+// accept https loopback redirect URLs. The redirect URL should use https.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *OAuth2Callback) {
+		o.tlsConfig = config
+	}
+}
+
+// GenerateLocalTLS returns a TLS config with a certificate for localhost,
+// 127.0.0.1 and ::1 kept in dir. When mkcert is installed the certificate is
+// issued by its locally-trusted CA; otherwise it is self-signed and the
+// browser shows a warning the first time. An existing, unexpired certificate
+// in dir is reused.
+func GenerateLocalTLS(dir string) (*tls.Config, error) {
+	certPath := filepath.Join(dir, localCertFile)
+	keyPath := filepath.Join(dir, localKeyFile)
+	if cert, err := loadLocalCert(certPath, keyPath); err == nil {
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create certificate directory: %v", err)
+	}
+	if path, err := exec.LookPath("mkcert"); err == nil {
+		out, err := exec.Command(path, "-cert-file", certPath, "-key-file", keyPath, "localhost", "127.0.0.1", "::1").CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("mkcert failed: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+	} else if err := writeSelfSignedCert(certPath, keyPath); err != nil {
+		return nil, err
+	}
+	cert, err := loadLocalCert(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+func loadLocalCert(certPath, keyPath string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if !time.Now().Before(leaf.NotAfter) {
+		return tls.Certificate{}, fmt.Errorf("certificate %s expired at %s", certPath, leaf.NotAfter.Format(time.RFC3339))
+	}
+	return cert, nil
+}
+
+func writeSelfSignedCert(certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write key: %v", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package googleoauth2callback
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// WithManualCodeEntry skips the local callback server and asks the user to
+// paste the authorization code, or the whole URL the browser was redirected
+// to, for redirect URIs the process cannot serve itself.
+func WithManualCodeEntry(manual bool) Option {
+	return func(o *OAuth2Callback) {
+		o.manualCodeEntry = manual
+	}
+}
+
+// readManualCode reads the pasted code from stdin. When ctx ends first, the
+// read is interrupted where stdin supports deadlines, such as a pipe; otherwise
+// it only returns once the next line arrives, and that line is discarded.
+func (o *OAuth2Callback) readManualCode(ctx context.Context, stateToken string) (string, error) {
+	fmt.Fprint(os.Stderr, "Paste the authorization code or the URL you were redirected to: ")
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		os.Stdin.SetReadDeadline(time.Now())
+		close(interrupted)
+	})
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if !stop() {
+		<-interrupted
+		os.Stdin.SetReadDeadline(time.Time{})
+	}
+	if ctx.Err() != nil {
+		return "", context.Cause(ctx)
+	}
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read authorization code: %v", err)
+	}
+	return o.parseManualCode(strings.TrimSpace(line), stateToken)
+}
+
+func (o *OAuth2Callback) parseManualCode(input, stateToken string) (string, error) {
+	if input == "" {
+		return "", fmt.Errorf("code not found in input")
+	}
+	if !strings.Contains(input, "?") {
+		if strings.IndexFunc(input, isControl) >= 0 {
+			return "", fmt.Errorf("control character in code")
+		}
+		return input, nil
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse redirected URL: %v", err)
+	}
+	query, err := checkCallbackQuery(u.RawQuery)
+	if err != nil {
+		return "", err
+	}
+	if errCode := query.Get("error"); errCode != "" {
+		return "", fmt.Errorf("authorization failed: %s", errCode)
+	}
+	if err := o.verifyState(stateToken, query.Get("state")); err != nil {
+		return "", err
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("code not found in redirected URL")
+	}
+	return code, nil
+}
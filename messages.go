@@ -0,0 +1,100 @@
+package googleoauth2callback
+
+import (
+	"net/http"
+	"strings"
+)
+
+type messageKey int
+
+const (
+	msgInvalidState messageKey = iota
+	msgCodeNotFound
+	msgExchangeFailed
+	msgWriteTokenFailed
+	msgScopesNotGranted
+	msgWrongAccount
+	msgSuccess
+)
+
+const defaultLocale = "en"
+
+var messages = map[string]map[messageKey]string{
+	"en": {
+		msgInvalidState:     "Invalid state token",
+		msgCodeNotFound:     "Code not found",
+		msgExchangeFailed:   "Failed to exchange token",
+		msgWriteTokenFailed: "Failed to write token file",
+		msgScopesNotGranted: "Some of the requested permissions were not granted. Please try again and allow all requested access.",
+		msgWrongAccount:     "This account cannot be used here. Please sign in with the expected Google account.",
+		msgSuccess:          "Authentication successful! You can close this tab and return to the console.",
+	},
+	"ja": {
+		msgInvalidState:     "state トークンが不正です",
+		msgCodeNotFound:     "認可コードが見つかりません",
+		msgExchangeFailed:   "トークンの交換に失敗しました",
+		msgWriteTokenFailed: "トークンファイルの書き込みに失敗しました",
+		msgScopesNotGranted: "要求した権限の一部が許可されませんでした。もう一度やり直し、すべてのアクセスを許可してください。",
+		msgWrongAccount:     "このアカウントはここでは使用できません。指定された Google アカウントでログインしてください。",
+		msgSuccess:          "認証に成功しました！このタブを閉じてコンソールに戻ってください。",
+	},
+	"es": {
+		msgInvalidState:     "Token de estado no válido",
+		msgCodeNotFound:     "No se encontró el código",
+		msgExchangeFailed:   "No se pudo intercambiar el token",
+		msgWriteTokenFailed: "No se pudo escribir el archivo de token",
+		msgScopesNotGranted: "No se concedieron algunos de los permisos solicitados. Vuelva a intentarlo y permita todo el acceso solicitado.",
+		msgWrongAccount:     "Esta cuenta no se puede usar aquí. Inicie sesión con la cuenta de Google esperada.",
+		msgSuccess:          "¡Autenticación correcta! Puede cerrar esta pestaña y volver a la consola.",
+	},
+	"fr": {
+		msgInvalidState:     "Jeton d'état invalide",
+		msgCodeNotFound:     "Code introuvable",
+		msgExchangeFailed:   "Échec de l'échange du jeton",
+		msgWriteTokenFailed: "Échec de l'écriture du fichier de jeton",
+		msgScopesNotGranted: "Certaines des autorisations demandées n'ont pas été accordées. Veuillez réessayer et autoriser tous les accès demandés.",
+		msgWrongAccount:     "Ce compte ne peut pas être utilisé ici. Veuillez vous connecter avec le compte Google attendu.",
+		msgSuccess:          "Authentification réussie ! Vous pouvez fermer cet onglet et revenir à la console.",
+	},
+	"de": {
+		msgInvalidState:     "Ungültiges State-Token",
+		msgCodeNotFound:     "Code nicht gefunden",
+		msgExchangeFailed:   "Token-Austausch fehlgeschlagen",
+		msgWriteTokenFailed: "Token-Datei konnte nicht geschrieben werden",
+		msgScopesNotGranted: "Einige der angeforderten Berechtigungen wurden nicht erteilt. Bitte versuchen Sie es erneut und erlauben Sie den gesamten angeforderten Zugriff.",
+		msgWrongAccount:     "Dieses Konto kann hier nicht verwendet werden. Bitte melden Sie sich mit dem erwarteten Google-Konto an.",
+		msgSuccess:          "Authentifizierung erfolgreich! Sie können diesen Tab schließen und zur Konsole zurückkehren.",
+	},
+}
+
+func WithLocale(locale string) Option {
+	return func(o *OAuth2Callback) {
+		o.locale = locale
+	}
+}
+
+func (o *OAuth2Callback) message(r *http.Request, key messageKey) string {
+	return messages[o.localeFor(r)][key]
+}
+
+func (o *OAuth2Callback) localeFor(r *http.Request) string {
+	if locale, ok := supportedLocale(o.locale); ok {
+		return locale
+	}
+	for _, tag := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ = strings.Cut(tag, ";")
+		if locale, ok := supportedLocale(tag); ok {
+			return locale
+		}
+	}
+	return defaultLocale
+}
+
+func supportedLocale(tag string) (string, bool) {
+	lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	lang = strings.ToLower(lang)
+	if _, ok := messages[lang]; ok {
+		return lang, true
+	}
+	return "", false
+}
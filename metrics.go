@@ -0,0 +1,119 @@
+package googleoauth2callback
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var exchangeDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metrics struct {
+	mu                   sync.Mutex
+	callbackRequests     uint64
+	invalidStateRequests uint64
+	tokenRefreshes       uint64
+	tokenRefreshFailures uint64
+	tokenExpiry          time.Time
+	exchangeCount        uint64
+	exchangeSum          float64
+	exchangeBucketCounts []uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		exchangeBucketCounts: make([]uint64, len(exchangeDurationBuckets)),
+	}
+}
+
+func (m *metrics) incCallbackRequests() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbackRequests++
+}
+
+func (m *metrics) incInvalidState() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.invalidStateRequests++
+}
+
+func (m *metrics) observeRefresh(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokenRefreshes++
+	if err != nil {
+		m.tokenRefreshFailures++
+	}
+}
+
+func (m *metrics) observeExchange(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seconds := d.Seconds()
+	m.exchangeCount++
+	m.exchangeSum += seconds
+	for i, le := range exchangeDurationBuckets {
+		if seconds <= le {
+			m.exchangeBucketCounts[i]++
+		}
+	}
+}
+
+func (m *metrics) setTokenExpiry(expiry time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokenExpiry = expiry
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	writeMetric(w, "googleoauth2callback_callback_requests_total", "counter",
+		"Total number of requests received by the callback server.", float64(m.callbackRequests))
+	writeMetric(w, "googleoauth2callback_invalid_state_total", "counter",
+		"Total number of callback requests with an invalid state token.", float64(m.invalidStateRequests))
+	writeMetric(w, "googleoauth2callback_token_refreshes_total", "counter",
+		"Total number of token refresh attempts.", float64(m.tokenRefreshes))
+	writeMetric(w, "googleoauth2callback_token_refresh_failures_total", "counter",
+		"Total number of failed token refresh attempts.", float64(m.tokenRefreshFailures))
+
+	expiry := 0.0
+	if !m.tokenExpiry.IsZero() {
+		expiry = float64(m.tokenExpiry.Unix())
+	}
+	writeMetric(w, "googleoauth2callback_token_expiry_timestamp_seconds", "gauge",
+		"Expiry of the current access token as a Unix timestamp.", expiry)
+
+	name := "googleoauth2callback_exchange_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Duration of authorization code exchanges.\n", name)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for i, le := range exchangeDurationBuckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, le, m.exchangeBucketCounts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(math.Inf(1)), m.exchangeCount)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(m.exchangeSum))
+	fmt.Fprintf(w, "%s_count %d\n", name, m.exchangeCount)
+}
+
+func writeMetric(w http.ResponseWriter, name, typ, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+func (o *OAuth2Callback) MetricsHandler() http.Handler {
+	return o.metrics
+}
@@ -0,0 +1,18 @@
+package googleoauth2callback
+
+import "net/http"
+
+// WithCallbackMiddleware wraps the callback handler. Middleware added first
+// runs first.
+func WithCallbackMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(o *OAuth2Callback) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+func (o *OAuth2Callback) applyMiddleware(h http.Handler) http.Handler {
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		h = o.middleware[i](h)
+	}
+	return h
+}
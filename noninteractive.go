@@ -0,0 +1,31 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInteractiveAuthRequired = errors.New("interactive authentication required")
+
+// WithNoInteractive never starts an authorization flow: no URL is printed, no
+// browser is opened and no port is bound. Without a usable cached token,
+// GetClient and Authenticate fail with ErrInteractiveAuthRequired instead, for
+// services deployed with a token obtained elsewhere.
+func WithNoInteractive(noInteractive bool) Option {
+	return func(o *OAuth2Callback) {
+		o.noInteractive = noInteractive
+	}
+}
+
+type noInteractionKey struct{}
+
+// withoutInteraction marks ctx so that building the shared token source fails
+// with ErrInteractiveAuthRequired instead of starting a flow, as with
+// WithNoInteractive but for a single call.
+func withoutInteraction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noInteractionKey{}, true)
+}
+
+func (o *OAuth2Callback) interactive(ctx context.Context) bool {
+	return !o.noInteractive && ctx.Value(noInteractionKey{}) == nil
+}
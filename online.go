@@ -0,0 +1,54 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// WithOnlineAccess requests access_type=online, for environments where
+// refresh tokens must not be stored. Only the access token is persisted, or
+// nothing with StorageModeNone, and when it expires the authorization flow runs
+// again instead of refreshing.
+func WithOnlineAccess(enabled bool) Option {
+	return func(o *OAuth2Callback) {
+		o.onlineAccess = enabled
+	}
+}
+
+func (o *OAuth2Callback) onlineTokenSource(ctx context.Context, tok *oauth2.Token) oauth2.TokenSource {
+	leeway := o.expiryLeeway
+	if leeway <= 0 {
+		leeway = defaultExpiryLeeway
+	}
+	src := &instrumentedTokenSource{
+		ctx:       ctx,
+		src:       &reauthTokenSource{ctx: ctx, callback: o},
+		telemetry: o.telemetry,
+		metrics:   o.metrics,
+	}
+	reuse := &reuseTokenSource{tok: tok, src: src, now: o.now, leeway: leeway}
+	o.refresher = nil
+	o.onlineSource = reuse
+	o.swapConfig = func(config *oauth2.Config) {}
+	return reuse
+}
+
+// reauthTokenSource replaces the refresher in online access mode.
+type reauthTokenSource struct {
+	ctx      context.Context
+	callback *OAuth2Callback
+}
+
+func (s *reauthTokenSource) Token() (*oauth2.Token, error) {
+	if s.callback.noInteractive {
+		return nil, fmt.Errorf("%w: the online access token expired", ErrInteractiveAuthRequired)
+	}
+	s.callback.debugf("online access token expired, authenticating again")
+	tok, err := s.callback.Authenticate(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate again: %v", err)
+	}
+	return tok, nil
+}
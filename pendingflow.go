@@ -0,0 +1,123 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNoPendingFlow is returned by ResumeFlow when no unexpired flow was
+// started with the given state.
+var ErrNoPendingFlow = errors.New("no pending authorization flow for state")
+
+// pendingFlowTTL bounds how long a flow started by BuildAuthURL can be resumed.
+const pendingFlowTTL = time.Hour
+
+type pendingFlow struct {
+	State       string    `json:"state"`
+	Verifier    string    `json:"verifier"`
+	Nonce       string    `json:"nonce,omitempty"`
+	Scopes      []string  `json:"scopes"`
+	RedirectURL string    `json:"redirect_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ResumeFlow completes a flow started by BuildAuthURL, possibly in another
+// process. code is the authorization code or the whole redirected URL, whose
+// state must then match. The resuming process must be configured with the same
+// credentials and scopes as the one that built the URL.
+func (o *OAuth2Callback) ResumeFlow(ctx context.Context, state, code string) (*oauth2.Token, error) {
+	flow, err := o.loadPendingFlow(state)
+	if err != nil {
+		return nil, err
+	}
+	config, err := o.createOAuth2Config()
+	if err != nil {
+		return nil, err
+	}
+	if !sameScopes(config.Scopes, flow.Scopes) {
+		return nil, fmt.Errorf("pending flow was started for scopes %q, not %q", flow.Scopes, config.Scopes)
+	}
+	config.RedirectURL = flow.RedirectURL
+
+	code, err = o.parseManualCode(strings.TrimSpace(code), flow.State)
+	if err != nil {
+		return nil, err
+	}
+	tok, _, _, err := o.redeemCode(ctx, config, code, flow.Nonce, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, err
+	}
+	o.removePendingFlow(state)
+	return tok, nil
+}
+
+func sameScopes(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func (o *OAuth2Callback) pendingFlowPath(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return filepath.Join(o.tokenDir(), "pending", hex.EncodeToString(sum[:])[:32]+".json")
+}
+
+// savePendingFlow does nothing with StorageModeNone, so such flows can only
+// be completed by the function BuildAuthURL returns.
+func (o *OAuth2Callback) savePendingFlow(flow *pendingFlow) error {
+	if o.storageMode == StorageModeNone {
+		return nil
+	}
+	path := o.pendingFlowPath(flow.State)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(flow)
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(path, b)
+}
+
+func (o *OAuth2Callback) loadPendingFlow(state string) (*pendingFlow, error) {
+	if o.storageMode == StorageModeNone {
+		return nil, ErrNoPendingFlow
+	}
+	b, err := os.ReadFile(o.pendingFlowPath(state))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoPendingFlow
+	}
+	if err != nil {
+		return nil, err
+	}
+	var flow pendingFlow
+	if err := json.Unmarshal(b, &flow); err != nil {
+		return nil, fmt.Errorf("failed to parse pending flow: %v", err)
+	}
+	if flow.State != state {
+		return nil, ErrNoPendingFlow
+	}
+	if o.now().Sub(flow.CreatedAt) > pendingFlowTTL {
+		o.removePendingFlow(state)
+		return nil, ErrNoPendingFlow
+	}
+	return &flow, nil
+}
+
+func (o *OAuth2Callback) removePendingFlow(state string) {
+	if err := os.Remove(o.pendingFlowPath(state)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		o.warn(fmt.Errorf("failed to remove pending flow: %v", err))
+	}
+}
@@ -0,0 +1,38 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var ErrTokenEndpointUnreachable = errors.New("token endpoint unreachable")
+
+const preflightTimeout = 10 * time.Second
+
+// WithPreflight checks that the token endpoint can be reached before the user
+// is sent to the browser, so proxy or firewall problems show up before consent
+// rather than when the code is exchanged.
+func WithPreflight(preflight bool) Option {
+	return func(o *OAuth2Callback) {
+		o.preflight = preflight
+	}
+}
+
+func (o *OAuth2Callback) checkTokenEndpoint(ctx context.Context, tokenURL string) error {
+	ctx, cancel := context.WithTimeout(o.tokenEndpointContext(ctx), preflightTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, tokenURL, nil)
+	if err != nil {
+		return err
+	}
+	// Any response means the endpoint is reachable; only transport errors count.
+	resp, err := contextClient(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTokenEndpointUnreachable, err)
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,64 @@
+package googleoauth2callback
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+func WithProgress(progress bool) Option {
+	return func(o *OAuth2Callback) {
+		o.progress = progress
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// startProgress shows how long the flow has been waiting for the browser. It
+// animates a spinner on terminals and prints a single status line otherwise.
+func (o *OAuth2Callback) startProgress() func() {
+	if !o.progress || o.manualCodeEntry {
+		return func() {}
+	}
+	if !isTerminal(os.Stderr) {
+		fmt.Fprintln(os.Stderr, "Waiting for you to authorize in the browser...")
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		start := time.Now()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			printProgress(os.Stderr, spinnerFrames[i%len(spinnerFrames)], time.Since(start))
+			select {
+			case <-ticker.C:
+			case <-stop:
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+func printProgress(w io.Writer, frame string, elapsed time.Duration) {
+	elapsed = elapsed.Truncate(time.Second)
+	fmt.Fprintf(w, "\r\033[K%s Waiting for you to authorize in the browser… %02d:%02d elapsed",
+		frame, int(elapsed.Minutes()), int(elapsed.Seconds())%60)
+}
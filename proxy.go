@@ -0,0 +1,67 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// WithProxyURL sends token endpoint, tokeninfo and Google API requests
+// through proxyURL instead of the proxy picked from HTTPS_PROXY and NO_PROXY.
+func WithProxyURL(proxyURL *url.URL) Option {
+	return func(o *OAuth2Callback) {
+		o.proxyURL = proxyURL
+	}
+}
+
+func (o *OAuth2Callback) proxyFunc() func(*http.Request) (*url.URL, error) {
+	if o.proxyURL != nil {
+		return http.ProxyURL(o.proxyURL)
+	}
+	return http.ProxyFromEnvironment
+}
+
+// withProxy returns base with the proxy applied. An *http.Transport without a
+// Proxy func, as injected clients often have, falls back to the environment.
+// Other RoundTrippers are returned unchanged.
+func (o *OAuth2Callback) withProxy(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		if o.proxyURL == nil {
+			return nil
+		}
+		base = http.DefaultTransport
+	}
+	t, ok := base.(*http.Transport)
+	if !ok || (o.proxyURL == nil && t.Proxy != nil) {
+		return base
+	}
+
+	o.proxyMu.Lock()
+	defer o.proxyMu.Unlock()
+	if clone, ok := o.proxyTransports[t]; ok {
+		return clone
+	}
+	clone := t.Clone()
+	clone.Proxy = o.proxyFunc()
+	if o.proxyTransports == nil {
+		o.proxyTransports = make(map[*http.Transport]*http.Transport)
+	}
+	o.proxyTransports[t] = clone
+	return clone
+}
+
+// proxyContext returns ctx with its oauth2.HTTPClient using the proxy.
+func (o *OAuth2Callback) proxyContext(ctx context.Context) context.Context {
+	client := &http.Client{}
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && c != nil {
+		*client = *c
+	}
+	transport := o.withProxy(client.Transport)
+	if transport == client.Transport {
+		return ctx
+	}
+	client.Transport = transport
+	return context.WithValue(ctx, oauth2.HTTPClient, client)
+}
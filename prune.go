@@ -0,0 +1,108 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// WithPruneInvalidTokens deletes the cached token, and its previous refresh
+// token backup, when Google rejects a refresh with invalid_grant, so a store
+// shared by many accounts does not accumulate revoked credentials. The next
+// process to load the token then starts a new flow instead of failing on it.
+func WithPruneInvalidTokens(enabled bool) Option {
+	return func(o *OAuth2Callback) {
+		o.pruneInvalid = enabled
+	}
+}
+
+// PruneTokens deletes cached tokens that have not been written or refreshed for
+// longer than olderThan and returns the accounts they belonged to. It covers the same
+// tokens as Accounts, so a token at a path set with WithTokenPath is never
+// pruned.
+func (o *OAuth2Callback) PruneTokens(ctx context.Context, olderThan time.Duration) ([]Account, error) {
+	accounts, err := o.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := o.now().Add(-olderThan)
+	var pruned []Account
+	for _, account := range accounts {
+		if account.UpdatedAt.IsZero() || !account.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if o.tokenStore != nil {
+			err = o.deleteStoredToken(ctx, account.Key)
+		} else {
+			err = removeTokenFile(filepath.Join(o.tokenDir(), "token-"+account.Key+".json"))
+		}
+		if err != nil {
+			return pruned, fmt.Errorf("failed to prune token %s: %v", account.Key, err)
+		}
+		pruned = append(pruned, account)
+	}
+	return pruned, nil
+}
+
+// touchToken records that the cached token was just refreshed, so that
+// PruneTokens does not take a token in daily use for a stale one. The token is
+// only touched while it still holds refreshToken.
+func (o *OAuth2Callback) touchToken(ctx context.Context, refreshToken string) {
+	if o.storageMode == StorageModeNone {
+		return
+	}
+	if o.tokenStore != nil {
+		key, err := o.resolveTokenNamespace()
+		if err != nil {
+			return
+		}
+		stored, err := o.tokenStore.Load(ctx, key)
+		if err != nil || stored.Token == nil || stored.Token.RefreshToken != refreshToken {
+			return
+		}
+		stored.UpdatedAt = o.now()
+		if err := o.tokenStore.Save(ctx, stored); err != nil {
+			o.warn(fmt.Errorf("failed to record token refresh: %v", err))
+		}
+		return
+	}
+	tokenPath, err := o.resolveTokenPath()
+	if err != nil {
+		return
+	}
+	now := o.now()
+	if err := os.Chtimes(tokenPath, now, now); err != nil && !errors.Is(err, os.ErrNotExist) {
+		o.warn(fmt.Errorf("failed to record token refresh: %v", err))
+	}
+}
+
+func (o *OAuth2Callback) handleRefreshError(err error, token *oauth2.Token, refreshToken string) {
+	if o.pruneInvalid && isInvalidGrant(err) {
+		o.pruneRevokedToken(context.Background(), refreshToken)
+	}
+	if o.onRefreshError != nil {
+		o.onRefreshError(err, token)
+	}
+}
+
+// pruneRevokedToken deletes the cached token if it still holds the rejected
+// refresh token. Another process may have rotated and saved a new one in the
+// meantime, which must survive.
+func (o *OAuth2Callback) pruneRevokedToken(ctx context.Context, rejected string) {
+	stored, err := o.readToken(ctx)
+	if err != nil {
+		return
+	}
+	if stored.RefreshToken != rejected {
+		o.debugf("cached refresh token changed since it was rejected, keeping it")
+		return
+	}
+	if err := o.deleteToken(ctx); err != nil {
+		o.warn(fmt.Errorf("failed to delete revoked token: %v", err))
+	}
+}
@@ -0,0 +1,119 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestPruneTokens(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	o := New()
+	tokenDir := o.tokenDir()
+	if err := os.MkdirAll(tokenDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"token-old.json", "token-old.json.previous", "token-new.json"} {
+		if err := os.WriteFile(filepath.Join(tokenDir, name), []byte(`{"access_token":"x"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(tokenDir, "token-old.json"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := o.PruneTokens(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || pruned[0].Key != "old" {
+		t.Fatalf("pruned = %+v, want only old", pruned)
+	}
+	for name, wantExists := range map[string]bool{"token-old.json": false, "token-old.json.previous": false, "token-new.json": true} {
+		_, err := os.Stat(filepath.Join(tokenDir, name))
+		if exists := err == nil; exists != wantExists {
+			t.Errorf("%s exists = %v, want %v", name, exists, wantExists)
+		}
+	}
+}
+
+func TestPruneInvalidTokensKeepsRotatedToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		stored      string
+		wantDeleted bool
+	}{
+		{name: "still the rejected token", stored: "rt1", wantDeleted: true},
+		{name: "rotated by another process", stored: "rt2", wantDeleted: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenPath := filepath.Join(t.TempDir(), "token.json")
+			o := New(WithTokenPath(tokenPath), WithPruneInvalidTokens(true))
+			if err := o.saveToken(context.Background(), &oauth2.Token{AccessToken: "at", RefreshToken: tt.stored}); err != nil {
+				t.Fatal(err)
+			}
+			o.handleRefreshError(newRefreshError(&oauth2.RetrieveError{ErrorCode: "invalid_grant"}), nil, "rt1")
+			_, err := os.Stat(tokenPath)
+			if deleted := errors.Is(err, os.ErrNotExist); deleted != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestPruneTokensKeepsRefreshedToken(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"fresh","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer tokenServer.Close()
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	o := New(
+		WithCredentialsPath(writeCredentials(t, dir, tokenServer.URL, "http://localhost:8080/callback")),
+		WithRedirectURL("http://localhost:8080/callback"),
+		WithNoInteractive(true),
+	)
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "rt", TokenType: "Bearer", Expiry: time.Now().Add(-time.Hour)}
+	if err := o.saveToken(context.Background(), expired); err != nil {
+		t.Fatal(err)
+	}
+	tokenPath, err := o.resolveTokenPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(tokenPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	// The refresh token is not rotated, so nothing rewrites the token file.
+	ts, err := o.tokenSource(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.Token(); err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := o.PruneTokens(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 0 {
+		t.Errorf("pruned = %+v, want the refreshed token kept", pruned)
+	}
+}
@@ -0,0 +1,37 @@
+package googleoauth2callback
+
+import "github.com/yuya-takeyama/googleoauth2callback/scopes"
+
+// newForProduct requests the product's read-only or read-write scope and
+// keeps its token in a namespace named after the product, so quickstarts for
+// different products do not overwrite each other's tokens. opts are applied
+// last and can override both.
+func newForProduct(product string, readonly bool, readonlyScope, scope string, opts []Option) *OAuth2Callback {
+	namespace := product
+	if readonly {
+		namespace += "-readonly"
+		scope = readonlyScope
+	}
+	return New(append([]Option{
+		WithScopes([]string{scope}),
+		WithTokenNamespace(namespace),
+	}, opts...)...)
+}
+
+func NewForDrive(readonly bool, opts ...Option) *OAuth2Callback {
+	return newForProduct("drive", readonly, scopes.DriveReadonly, scopes.Drive, opts)
+}
+
+// NewForGmail requests gmail.modify when readonly is false rather than full
+// mail access, which also allows permanent deletion.
+func NewForGmail(readonly bool, opts ...Option) *OAuth2Callback {
+	return newForProduct("gmail", readonly, scopes.GmailReadonly, scopes.GmailModify, opts)
+}
+
+func NewForCalendar(readonly bool, opts ...Option) *OAuth2Callback {
+	return newForProduct("calendar", readonly, scopes.CalendarReadonly, scopes.Calendar, opts)
+}
+
+func NewForSheets(readonly bool, opts ...Option) *OAuth2Callback {
+	return newForProduct("sheets", readonly, scopes.SpreadsheetsReadonly, scopes.Spreadsheets, opts)
+}
@@ -0,0 +1,61 @@
+package googleoauth2callback
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrTooManyInvalidStates = errors.New("too many callback requests with an invalid state")
+
+const (
+	defaultInvalidStateLimit = 10
+	callbackRateLimit        = 20
+	callbackRateWindow       = time.Minute
+)
+
+// WithInvalidStateLimit aborts the flow with ErrTooManyInvalidStates once this
+// many callback requests carried a wrong state, which points at something
+// probing the port rather than a user retrying. Zero disables the limit.
+func WithInvalidStateLimit(limit int) Option {
+	return func(o *OAuth2Callback) {
+		o.invalidStateLimit = limit
+	}
+}
+
+// rateLimiter allows limit requests per client within a fixed window.
+type rateLimiter struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	now         func() time.Time
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func newRateLimiter(limit int, window time.Duration, now func() time.Time) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		now:    now,
+		counts: make(map[string]int),
+	}
+}
+
+func (l *rateLimiter) allow(r *http.Request) bool {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := l.now(); now.Sub(l.windowStart) >= l.window {
+		l.windowStart = now
+		clear(l.counts)
+	}
+	l.counts[client]++
+	return l.counts[client] <= l.limit
+}
@@ -0,0 +1,80 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCallbackRateLimitSparesValidState(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"at","refresh_token":"rt","token_type":"Bearer","expires_in":3600,"scope":"email"}`)
+	}))
+	defer tokenServer.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	redirectURL := fmt.Sprintf("http://127.0.0.1:%d/callback", port)
+
+	dir := t.TempDir()
+	credentialsPath := filepath.Join(dir, "credentials.json")
+	creds := fmt.Sprintf(`{"web":{"client_id":"cid","client_secret":"sec","auth_uri":"%s/auth","token_uri":"%s/token","redirect_uris":[%q]}}`, tokenServer.URL, tokenServer.URL, redirectURL)
+	if err := os.WriteFile(credentialsPath, []byte(creds), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ready := make(chan string, 1)
+	o := New(
+		WithCredentialsPath(credentialsPath),
+		WithTokenPath(filepath.Join(dir, "token.json")),
+		WithRedirectURL(redirectURL),
+		WithScopes([]string{"email"}),
+		WithInvalidStateLimit(0),
+		WithReadyHook(func(authURL string) { ready <- authURL }),
+	)
+	status := make(chan int, 1)
+	go func() {
+		u, _ := url.Parse(<-ready)
+		limited := false
+		for range callbackRateLimit + 5 {
+			res, err := http.Get(redirectURL + "?state=wrong&code=x")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res.Body.Close()
+			limited = limited || res.StatusCode == http.StatusTooManyRequests
+		}
+		if !limited {
+			t.Error("requests with a wrong state were not rate limited")
+		}
+		res, err := http.Get(redirectURL + "?code=abc&state=" + url.QueryEscape(u.Query().Get("state")))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		res.Body.Close()
+		status <- res.StatusCode
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := o.Authenticate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("valid callback status = %d, want 200", code)
+	}
+}
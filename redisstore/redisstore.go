@@ -0,0 +1,195 @@
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+)
+
+var ErrConflict = errors.New("token was modified concurrently")
+
+// saveScript only writes when the stored version still matches the version this
+// replica last loaded, so concurrent writers cannot overwrite each other.
+const saveScript = `local current = redis.call('HGET', KEYS[1], 'version') or '0'
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', tostring(tonumber(current) + 1), 'data', ARGV[2])
+return 1`
+
+const unlockScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+
+type Store struct {
+	addr      string
+	password  string
+	db        int
+	keyPrefix string
+	dialer    net.Dialer
+
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+type Option func(*Store)
+
+func WithPassword(password string) Option {
+	return func(s *Store) {
+		s.password = password
+	}
+}
+
+func WithDB(db int) Option {
+	return func(s *Store) {
+		s.db = db
+	}
+}
+
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.keyPrefix = prefix
+	}
+}
+
+func New(addr string, opts ...Option) *Store {
+	store := &Store{
+		addr:      addr,
+		keyPrefix: "googleoauth2callback:",
+		versions:  make(map[string]string),
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	return store
+}
+
+func (s *Store) Load(ctx context.Context, key string) (*googleoauth2callback.StoredToken, error) {
+	reply, err := s.do(ctx, "HMGET", s.keyPrefix+key, "version", "data")
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("redis: unexpected HMGET reply")
+	}
+	version, _ := values[0].(string)
+	data, _ := values[1].(string)
+	if data == "" {
+		return nil, googleoauth2callback.ErrTokenNotFound
+	}
+
+	var stored googleoauth2callback.StoredToken
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse stored token: %v", err)
+	}
+
+	s.mu.Lock()
+	s.versions[key] = version
+	s.mu.Unlock()
+	return &stored, nil
+}
+
+func (s *Store) Save(ctx context.Context, stored *googleoauth2callback.StoredToken) error {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+
+	s.mu.Lock()
+	version, ok := s.versions[stored.Key]
+	s.mu.Unlock()
+	if !ok {
+		version = "0"
+	}
+
+	reply, err := s.do(ctx, "EVAL", saveScript, "1", s.keyPrefix+stored.Key, version, string(data))
+	if err != nil {
+		return err
+	}
+	if n, _ := reply.(int64); n != 1 {
+		return ErrConflict
+	}
+
+	next, err := strconv.Atoi(version)
+	if err != nil {
+		return fmt.Errorf("redis: invalid version %q", version)
+	}
+	s.mu.Lock()
+	s.versions[stored.Key] = strconv.Itoa(next + 1)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if _, err := s.do(ctx, "DEL", s.keyPrefix+key); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.versions, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// Lock acquires a short-lived lock for key, so that only one replica refreshes
+// a shared token at a time. It returns false if another replica holds the lock.
+func (s *Store) Lock(ctx context.Context, key string, ttl time.Duration) (unlock func() error, acquired bool, err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, false, err
+	}
+	owner := hex.EncodeToString(b)
+	lockKey := s.keyPrefix + "lock:" + key
+
+	reply, err := s.do(ctx, "SET", lockKey, owner, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if errors.Is(err, errNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if reply != "OK" {
+		return nil, false, nil
+	}
+	return func() error {
+		_, err := s.do(context.Background(), "EVAL", unlockScript, "1", lockKey, owner)
+		return err
+	}, true, nil
+}
+
+func (s *Store) do(ctx context.Context, args ...string) (any, error) {
+	nc, err := s.dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %v", err)
+	}
+	defer nc.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	if s.password != "" {
+		if _, err := c.do("AUTH", s.password); err != nil {
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(s.db)); err != nil {
+			return nil, err
+		}
+	}
+	return c.do(args...)
+}
@@ -0,0 +1,52 @@
+package googleoauth2callback
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// RefreshError describes a failed access token refresh. Code and Description
+// hold the OAuth error returned by the token endpoint, such as "invalid_grant"
+// for a revoked or expired refresh token or "invalid_client" for deleted
+// credentials; both are empty when the endpoint could not be reached.
+type RefreshError struct {
+	Code        string
+	Description string
+	StatusCode  int
+	Err         error
+}
+
+func (e *RefreshError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("token refresh failed: %v", e.Err)
+	}
+	return fmt.Sprintf("token refresh failed: %s", e.Code)
+}
+
+func (e *RefreshError) Unwrap() error {
+	return e.Err
+}
+
+// WithOnRefreshError registers a function called whenever refreshing the access
+// token fails, with a *RefreshError and the last token that was issued, so a
+// long-running process can alert someone instead of failing API calls quietly.
+func WithOnRefreshError(handler func(err error, token *oauth2.Token)) Option {
+	return func(o *OAuth2Callback) {
+		o.onRefreshError = handler
+	}
+}
+
+func newRefreshError(err error) *RefreshError {
+	refreshErr := &RefreshError{Err: err}
+	var re *oauth2.RetrieveError
+	if errors.As(err, &re) {
+		refreshErr.Code = re.ErrorCode
+		refreshErr.Description = re.ErrorDescription
+		if re.Response != nil {
+			refreshErr.StatusCode = re.Response.StatusCode
+		}
+	}
+	return refreshErr
+}
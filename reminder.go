@@ -0,0 +1,80 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var ErrAuthTimeout = errors.New("timed out waiting for authorization")
+
+// WithMaxWait limits how long Authenticate waits for the user to authorize
+// before it fails with ErrAuthTimeout.
+func WithMaxWait(d time.Duration) Option {
+	return func(o *OAuth2Callback) {
+		o.maxWait = d
+	}
+}
+
+// WithReminderInterval prints the authorization URL again at the given
+// interval while waiting, with the time left when a deadline is set, so it is
+// not lost in scrollback.
+func WithReminderInterval(interval time.Duration) Option {
+	return func(o *OAuth2Callback) {
+		o.reminderInterval = interval
+	}
+}
+
+// WithReminderHook is called at every reminder instead of printing, e.g. to
+// show the URL again in a GUI. remaining is zero without a deadline.
+func WithReminderHook(hook func(authURL string, remaining time.Duration)) Option {
+	return func(o *OAuth2Callback) {
+		o.reminderHook = hook
+	}
+}
+
+func (o *OAuth2Callback) waitContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.maxWait <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, o.maxWait, fmt.Errorf("%w after %s", ErrAuthTimeout, o.maxWait))
+}
+
+// startReminders repeats the authorization URL until ctx is done or the
+// returned function is called.
+func (o *OAuth2Callback) startReminders(ctx context.Context, authURL string) func() {
+	if o.reminderInterval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(o.reminderInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			var remaining time.Duration
+			if deadline, ok := ctx.Deadline(); ok {
+				remaining = time.Until(deadline).Round(time.Second)
+			}
+			if o.reminderHook != nil {
+				o.reminderHook(authURL, remaining)
+				continue
+			}
+			// Clear the progress spinner's line before printing.
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			if remaining > 0 {
+				fmt.Fprintf(os.Stderr, "Still waiting for authorization (%s left). Visit this url if the browser did not open:\n", remaining)
+			} else {
+				fmt.Fprintln(os.Stderr, "Still waiting for authorization. Visit this url if the browser did not open:")
+			}
+			fmt.Fprintln(os.Stderr, authURL)
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,41 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type AuthResult struct {
+	Token              *oauth2.Token
+	GrantedScopes      []string
+	AccountEmail       string
+	IDTokenClaims      *IDTokenClaims
+	RefreshTokenIssued bool
+	Duration           time.Duration
+	Timings            FlowTimings
+}
+
+// AuthenticateWithResult runs Authenticate and describes what the flow
+// produced.
+func (o *OAuth2Callback) AuthenticateWithResult(ctx context.Context) (*AuthResult, error) {
+	start := time.Now()
+	tok, err := o.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &AuthResult{
+		Token:              tok,
+		IDTokenClaims:      o.IDTokenClaims(),
+		RefreshTokenIssued: tok.RefreshToken != "",
+		Duration:           time.Since(start),
+		Timings:            o.FlowStatus().Timings,
+	}
+	if scope, ok := tok.Extra("scope").(string); ok {
+		result.GrantedScopes = strings.Fields(scope)
+	}
+	result.AccountEmail = o.currentAccountEmail()
+	return result, nil
+}
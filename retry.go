@@ -0,0 +1,106 @@
+package googleoauth2callback
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	retryInitialBackoff = 500 * time.Millisecond
+	retryMaxBackoff     = 30 * time.Second
+)
+
+// WithRetryTransport makes the returned clients retry requests that fail with
+// 429 or a 5xx status up to maxRetries times, honoring Retry-After. Only
+// idempotent methods, and requests carrying an Idempotency-Key header, are
+// retried on 5xx; other requests such as POST are retried on 429 only, since
+// the server may have acted on them before failing.
+func WithRetryTransport(maxRetries int) Option {
+	return func(o *OAuth2Callback) {
+		o.apiRetries = maxRetries
+	}
+}
+
+func (o *OAuth2Callback) withRetry(base http.RoundTripper) http.RoundTripper {
+	if o.apiRetries <= 0 {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{base: base, maxRetries: o.apiRetries, now: o.now}
+}
+
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	now        func() time.Time
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := retryInitialBackoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || !isRetryable(req, resp.StatusCode) || attempt >= t.maxRetries {
+			return resp, err
+		}
+		// A request whose body cannot be replayed is only sent once.
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"), t.now())
+		if wait <= 0 {
+			wait = backoff
+			backoff = min(backoff*2, retryMaxBackoff)
+		}
+		resp.Body.Close()
+
+		timer := time.NewTimer(min(wait, retryMaxBackoff))
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func isRetryable(req *http.Request, status int) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	return status >= 500 && status <= 599 && isIdempotent(req)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryAfter parses a Retry-After value given either in seconds or as an HTTP
+// date.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package googleoauth2callback
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRetryTransportMethods(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		idempotencyKey string
+		status         int
+		wantAttempts   int64
+	}{
+		{name: "GET 503", method: http.MethodGet, status: http.StatusServiceUnavailable, wantAttempts: 2},
+		{name: "PUT 500", method: http.MethodPut, status: http.StatusInternalServerError, wantAttempts: 2},
+		{name: "DELETE 502", method: http.MethodDelete, status: http.StatusBadGateway, wantAttempts: 2},
+		{name: "POST 503", method: http.MethodPost, status: http.StatusServiceUnavailable, wantAttempts: 1},
+		{name: "PATCH 500", method: http.MethodPatch, status: http.StatusInternalServerError, wantAttempts: 1},
+		{name: "POST 503 with Idempotency-Key", method: http.MethodPost, idempotencyKey: "k", status: http.StatusServiceUnavailable, wantAttempts: 2},
+		{name: "POST 429", method: http.MethodPost, status: http.StatusTooManyRequests, wantAttempts: 2},
+		{name: "GET 404", method: http.MethodGet, status: http.StatusNotFound, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			o := New(WithRetryTransport(1))
+			client := &http.Client{Transport: o.withRetry(nil)}
+			req, err := http.NewRequest(tt.method, srv.URL, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tt.idempotencyKey)
+			}
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
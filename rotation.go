@@ -0,0 +1,85 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/oauth2"
+)
+
+// The previous refresh token is kept next to the current one so that a
+// rotated token that never made it to storage does not lock the user out.
+const previousTokenSuffix = ".previous"
+
+func (o *OAuth2Callback) savePreviousRefreshToken(ctx context.Context, refreshToken string) error {
+	if o.storageMode == StorageModeNone {
+		return nil
+	}
+	backup := &oauth2.Token{RefreshToken: refreshToken}
+	if o.tokenStore != nil {
+		key, err := o.resolveTokenNamespace()
+		if err != nil {
+			return err
+		}
+		return o.tokenStore.Save(ctx, &StoredToken{
+			Key:       key + previousTokenSuffix,
+			Token:     backup,
+			UpdatedAt: o.now(),
+		})
+	}
+	tokenPath, err := o.resolveTokenPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(backup)
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(tokenPath+previousTokenSuffix, b)
+}
+
+func (o *OAuth2Callback) loadPreviousRefreshToken(ctx context.Context) string {
+	if o.storageMode == StorageModeNone {
+		return ""
+	}
+	var tok oauth2.Token
+	if o.tokenStore != nil {
+		key, err := o.resolveTokenNamespace()
+		if err != nil {
+			return ""
+		}
+		stored, err := o.tokenStore.Load(ctx, key+previousTokenSuffix)
+		if err != nil || stored.Token == nil {
+			return ""
+		}
+		return stored.Token.RefreshToken
+	}
+	tokenPath, err := o.resolveTokenPath()
+	if err != nil {
+		return ""
+	}
+	b, err := os.ReadFile(tokenPath + previousTokenSuffix)
+	if err != nil || json.Unmarshal(b, &tok) != nil {
+		return ""
+	}
+	return tok.RefreshToken
+}
+
+// rotateRefreshToken persists a token whose refresh token was rotated, backing
+// up the previous refresh token first.
+func (o *OAuth2Callback) rotateRefreshToken(ctx context.Context, previous string, tok *oauth2.Token) {
+	if err := o.savePreviousRefreshToken(ctx, previous); err != nil {
+		o.warn(fmt.Errorf("failed to back up previous refresh token: %v", err))
+	}
+	if err := o.saveToken(ctx, tok); err != nil {
+		o.warn(fmt.Errorf("failed to save rotated refresh token: %v", err))
+	}
+}
+
+func isInvalidGrant(err error) bool {
+	var re *oauth2.RetrieveError
+	return errors.As(err, &re) && re.ErrorCode == "invalid_grant"
+}
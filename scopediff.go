@@ -0,0 +1,75 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"slices"
+)
+
+type ScopeDiff struct {
+	Requested []string
+	// Stored is nil when the token storage does not record scopes, as with
+	// the default token file.
+	Stored  []string
+	Granted []string
+
+	NotStored   []string
+	NotGranted  []string
+	Unrequested []string
+}
+
+// DiffScopes compares the requested scopes with the scopes recorded next to
+// the cached token and with what Google reports the token actually grants.
+func (o *OAuth2Callback) DiffScopes(ctx context.Context) (*ScopeDiff, error) {
+	info, err := o.IntrospectToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	diff := &ScopeDiff{
+		Requested: o.scopes,
+		Granted:   info.Scopes,
+	}
+
+	if o.tokenStore != nil {
+		key, err := o.resolveTokenNamespace()
+		if err != nil {
+			return nil, err
+		}
+		stored, err := o.tokenStore.Load(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		diff.Stored = stored.Scopes
+		diff.NotStored = scopesMissingFrom(o.scopes, stored.Scopes)
+	}
+
+	diff.NotGranted = scopesMissingFrom(o.scopes, info.Scopes)
+	for _, scope := range info.Scopes {
+		if !slices.Contains(o.scopes, scope) && !isAliasOf(scope, o.scopes) {
+			diff.Unrequested = append(diff.Unrequested, scope)
+		}
+	}
+	return diff, nil
+}
+
+func scopesMissingFrom(requested, have []string) []string {
+	var missing []string
+	for _, scope := range requested {
+		if slices.Contains(have, scope) {
+			continue
+		}
+		if alias, ok := scopeAliases[scope]; ok && slices.Contains(have, alias) {
+			continue
+		}
+		missing = append(missing, scope)
+	}
+	return missing
+}
+
+func isAliasOf(scope string, scopes []string) bool {
+	for _, s := range scopes {
+		if scopeAliases[s] == scope {
+			return true
+		}
+	}
+	return false
+}
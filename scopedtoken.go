@@ -0,0 +1,131 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenSourceForScopes returns a token source for a subset of the scopes the
+// cached token was granted. Access tokens are minted from the same refresh
+// token with only those scopes, so no new consent is needed. Sources are kept
+// per scope set, so clients for the same scopes share their tokens.
+func (o *OAuth2Callback) TokenSourceForScopes(ctx context.Context, scopes ...string) (oauth2.TokenSource, error) {
+	if _, err := o.tokenSource(ctx); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, scope := range scopesMissingFrom(scopes, o.scopes) {
+		if !isAliasOf(scope, o.scopes) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrScopesNotGranted, strings.Join(missing, " "))
+	}
+
+	sorted := slices.Clone(scopes)
+	slices.Sort(sorted)
+	key := strings.Join(slices.Compact(sorted), " ")
+
+	o.tokenSourceMu.Lock()
+	defer o.tokenSourceMu.Unlock()
+	if o.refresher == nil {
+		return nil, fmt.Errorf("scoped token sources require a user OAuth client token")
+	}
+	if ts, ok := o.scopedSources[key]; ok {
+		return ts, nil
+	}
+	leeway := o.expiryLeeway
+	if leeway <= 0 {
+		leeway = defaultExpiryLeeway
+	}
+	ts := &reuseTokenSource{
+		src:    &scopedTokenSource{refresher: o.refresher, scopes: strings.Fields(key), now: o.now},
+		now:    o.now,
+		leeway: leeway,
+	}
+	if o.scopedSources == nil {
+		o.scopedSources = make(map[string]oauth2.TokenSource)
+	}
+	o.scopedSources[key] = ts
+	return ts, nil
+}
+
+func (o *OAuth2Callback) ClientForScopes(ctx context.Context, scopes ...string) (*http.Client, error) {
+	ts, err := o.TokenSourceForScopes(ctx, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	return o.newClient(ctx, ts), nil
+}
+
+type scopedTokenSource struct {
+	refresher *refreshingTokenSource
+	scopes    []string
+	now       func() time.Time
+}
+
+func (s *scopedTokenSource) Token() (*oauth2.Token, error) {
+	s.refresher.mu.Lock()
+	ctx, config, refreshToken := s.refresher.ctx, s.refresher.config, s.refresher.refreshToken
+	s.refresher.mu.Unlock()
+	return refreshWithScopes(ctx, config, refreshToken, s.scopes, s.now)
+}
+
+// refreshWithScopes redeems refreshToken for an access token limited to
+// scopes. oauth2.Config cannot add the scope parameter to a refresh request,
+// so the request is made directly. The expiry is computed from now.
+func refreshWithScopes(ctx context.Context, config *oauth2.Config, refreshToken string, scopes []string, now func() time.Time) (*oauth2.Token, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {config.ClientID},
+		"client_secret": {config.ClientSecret},
+		"scope":         {strings.Join(scopes, " ")},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := contextClient(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh scoped token: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Scope            string `json:"scope"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode scoped token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return nil, &oauth2.RetrieveError{
+			Response:         resp,
+			ErrorCode:        body.Error,
+			ErrorDescription: body.ErrorDescription,
+		}
+	}
+	tok := &oauth2.Token{
+		AccessToken: body.AccessToken,
+		TokenType:   body.TokenType,
+		ExpiresIn:   body.ExpiresIn,
+	}
+	if body.ExpiresIn > 0 {
+		tok.Expiry = now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return tok.WithExtra(map[string]any{"scope": body.Scope}), nil
+}
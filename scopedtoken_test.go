@@ -0,0 +1,33 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshWithScopesUsesClock(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("scope"); got != "email" {
+			t.Errorf("scope = %q, want email", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"at","token_type":"Bearer","expires_in":3600,"scope":"email"}`)
+	}))
+	defer tokenServer.Close()
+	config := &oauth2.Config{ClientID: "cid", Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL}}
+
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tok, err := refreshWithScopes(context.Background(), config, "rt", []string{"email"}, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(time.Hour); !tok.Expiry.Equal(want) {
+		t.Errorf("expiry = %s, want %s", tok.Expiry, want)
+	}
+}
@@ -0,0 +1,53 @@
+package scopes
+
+var descriptions = map[string]string{
+	OpenID:  "Associate you with your personal info on Google",
+	Email:   "See your primary Google Account email address",
+	Profile: "See your personal info, including any personal info you've made publicly available",
+
+	CloudPlatform:         "See, edit, configure, and delete your Google Cloud data",
+	CloudPlatformReadOnly: "View your data across Google Cloud services",
+
+	Drive:         "See, edit, create, and delete all of your Google Drive files",
+	DriveReadonly: "See and download all your Google Drive files",
+	DriveFile:     "See, edit, create, and delete only the specific Google Drive files you use with this app",
+	DriveMetadata: "See information about your Google Drive files",
+
+	Gmail:         "Read, compose, send, and permanently delete all your email from Gmail",
+	GmailReadonly: "View your email messages and settings",
+	GmailSend:     "Send email on your behalf",
+	GmailCompose:  "Manage drafts and send emails",
+	GmailModify:   "Read, compose, and send emails from your Gmail account",
+
+	Calendar:         "See, edit, share, and permanently delete all the calendars you can access using Google Calendar",
+	CalendarReadonly: "See and download any calendar you can access using your Google Calendar",
+	CalendarEvents:   "View and edit events on all your calendars",
+
+	Spreadsheets:         "See, edit, create, and delete all your Google Sheets spreadsheets",
+	SpreadsheetsReadonly: "See all your Google Sheets spreadsheets",
+
+	Documents:         "See, edit, create, and delete all your Google Docs documents",
+	DocumentsReadonly: "See all your Google Docs documents",
+
+	Presentations:         "See, edit, create, and delete all your Google Slides presentations",
+	PresentationsReadonly: "See all your Google Slides presentations",
+
+	Tasks:         "Create, edit, organize, and delete all your tasks",
+	TasksReadonly: "View your tasks",
+
+	Contacts:         "See, edit, download, and permanently delete your contacts",
+	ContactsReadonly: "See and download your contacts",
+
+	YouTube:         "Manage your YouTube account",
+	YouTubeReadonly: "View your YouTube account",
+
+	UserInfoEmail:   "See your primary Google Account email address",
+	UserInfoProfile: "See your personal info, including any personal info you've made publicly available",
+}
+
+// Describe returns what scope allows in the words of Google's consent screen,
+// or false for scopes it does not know.
+func Describe(scope string) (string, bool) {
+	description, ok := descriptions[scope]
+	return description, ok
+}
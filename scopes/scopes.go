@@ -0,0 +1,81 @@
+package scopes
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	OpenID  = "openid"
+	Email   = "email"
+	Profile = "profile"
+
+	CloudPlatform         = "https://www.googleapis.com/auth/cloud-platform"
+	CloudPlatformReadOnly = "https://www.googleapis.com/auth/cloud-platform.read-only"
+
+	Drive         = "https://www.googleapis.com/auth/drive"
+	DriveReadonly = "https://www.googleapis.com/auth/drive.readonly"
+	DriveFile     = "https://www.googleapis.com/auth/drive.file"
+	DriveMetadata = "https://www.googleapis.com/auth/drive.metadata.readonly"
+
+	Gmail         = "https://mail.google.com/"
+	GmailReadonly = "https://www.googleapis.com/auth/gmail.readonly"
+	GmailSend     = "https://www.googleapis.com/auth/gmail.send"
+	GmailCompose  = "https://www.googleapis.com/auth/gmail.compose"
+	GmailModify   = "https://www.googleapis.com/auth/gmail.modify"
+
+	Calendar         = "https://www.googleapis.com/auth/calendar"
+	CalendarReadonly = "https://www.googleapis.com/auth/calendar.readonly"
+	CalendarEvents   = "https://www.googleapis.com/auth/calendar.events"
+
+	Spreadsheets         = "https://www.googleapis.com/auth/spreadsheets"
+	SpreadsheetsReadonly = "https://www.googleapis.com/auth/spreadsheets.readonly"
+
+	Documents         = "https://www.googleapis.com/auth/documents"
+	DocumentsReadonly = "https://www.googleapis.com/auth/documents.readonly"
+
+	Presentations         = "https://www.googleapis.com/auth/presentations"
+	PresentationsReadonly = "https://www.googleapis.com/auth/presentations.readonly"
+
+	Tasks         = "https://www.googleapis.com/auth/tasks"
+	TasksReadonly = "https://www.googleapis.com/auth/tasks.readonly"
+
+	Contacts         = "https://www.googleapis.com/auth/contacts"
+	ContactsReadonly = "https://www.googleapis.com/auth/contacts.readonly"
+
+	YouTube         = "https://www.googleapis.com/auth/youtube"
+	YouTubeReadonly = "https://www.googleapis.com/auth/youtube.readonly"
+
+	UserInfoEmail   = "https://www.googleapis.com/auth/userinfo.email"
+	UserInfoProfile = "https://www.googleapis.com/auth/userinfo.profile"
+)
+
+func Validate(scope string) error {
+	switch scope {
+	case OpenID, Email, Profile:
+		return nil
+	case "":
+		return fmt.Errorf("scope must not be empty")
+	}
+	if strings.ContainsAny(scope, " \t\n") {
+		return fmt.Errorf("scope %q must not contain whitespace", scope)
+	}
+	u, err := url.Parse(scope)
+	if err != nil {
+		return fmt.Errorf("scope %q is not a valid URL: %v", scope, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("scope %q must be an https URL", scope)
+	}
+	return nil
+}
+
+func ValidateAll(scopes []string) error {
+	for _, scope := range scopes {
+		if err := Validate(scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package secretmanagerstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+)
+
+const endpoint = "https://secretmanager.googleapis.com/v1"
+
+var errSecretNotFound = errors.New("secret not found")
+
+var invalidSecretIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+type Store struct {
+	client       *http.Client
+	project      string
+	secretPrefix string
+}
+
+type Option func(*Store)
+
+func WithSecretPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.secretPrefix = prefix
+	}
+}
+
+// New returns a store backed by Google Secret Manager. client must be
+// authorized for the cloud-platform scope, e.g. one from google.DefaultClient.
+func New(client *http.Client, project string, opts ...Option) *Store {
+	store := &Store{
+		client:       client,
+		project:      project,
+		secretPrefix: "googleoauth2callback",
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	return store
+}
+
+func (s *Store) Load(ctx context.Context, key string) (*googleoauth2callback.StoredToken, error) {
+	res, err := s.do(ctx, http.MethodGet, s.secretPath(key)+"/versions/latest:access", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, googleoauth2callback.ErrTokenNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError("access", res)
+	}
+
+	var body struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse Secret Manager response: %v", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(body.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret payload: %v", err)
+	}
+	var stored googleoauth2callback.StoredToken
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse stored token: %v", err)
+	}
+	return &stored, nil
+}
+
+func (s *Store) Save(ctx context.Context, stored *googleoauth2callback.StoredToken) error {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+	payload, err := json.Marshal(map[string]any{
+		"payload": map[string]string{"data": base64.StdEncoding.EncodeToString(data)},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.addVersion(ctx, stored.Key, payload)
+	if errors.Is(err, errSecretNotFound) {
+		if err := s.createSecret(ctx, stored.Key); err != nil {
+			return err
+		}
+		err = s.addVersion(ctx, stored.Key, payload)
+	}
+	return err
+}
+
+func (s *Store) addVersion(ctx context.Context, key string, payload []byte) error {
+	res, err := s.do(ctx, http.MethodPost, s.secretPath(key)+":addVersion", payload)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return errSecretNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return responseError("add version to", res)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, s.secretPath(key), nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return responseError("delete", res)
+	}
+	return nil
+}
+
+func (s *Store) createSecret(ctx context.Context, key string) error {
+	payload := []byte(`{"replication":{"automatic":{}}}`)
+	path := fmt.Sprintf("/projects/%s/secrets?secretId=%s", s.project, s.secretID(key))
+	res, err := s.do(ctx, http.MethodPost, path, payload)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusConflict {
+		return responseError("create", res)
+	}
+	return nil
+}
+
+func (s *Store) secretID(key string) string {
+	return invalidSecretIDChars.ReplaceAllString(s.secretPrefix+"-"+key, "_")
+}
+
+func (s *Store) secretPath(key string) string {
+	return fmt.Sprintf("/projects/%s/secrets/%s", s.project, s.secretID(key))
+}
+
+func (s *Store) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Secret Manager request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Secret Manager request failed: %v", err)
+	}
+	return res, nil
+}
+
+func responseError(op string, res *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	return fmt.Errorf("failed to %s secret: %s: %s", op, res.Status, strings.TrimSpace(string(b)))
+}
@@ -0,0 +1,230 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxCallbackConns          = 8
+	maxCallbackHeaderBytes    = 8 << 10
+	maxCallbackBodyBytes      = 1 << 10
+	callbackReadHeaderTimeout = 10 * time.Second
+)
+
+type CallbackServer struct {
+	redirectURL string
+	mu          sync.Mutex
+	flows       map[string]http.Handler
+	srv         *http.Server
+	wait        func()
+}
+
+func NewCallbackServer(redirectURL string) *CallbackServer {
+	return &CallbackServer{
+		redirectURL: redirectURL,
+		flows:       make(map[string]http.Handler),
+	}
+}
+
+func WithCallbackServer(server *CallbackServer) Option {
+	return func(o *OAuth2Callback) {
+		o.callbackServer = server
+		o.redirectURL = server.redirectURL
+	}
+}
+
+func (s *CallbackServer) Start() error {
+	host, port, callbackPath, err := splitRedirectURL(s.redirectURL)
+	if err != nil {
+		return err
+	}
+	listeners, err := listen(host, port)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.srv = newHTTPServer(withResponseHeaders(defaultResponseHeaders(), callbackGuard(host, callbackPath, http.HandlerFunc(s.dispatch))))
+	s.wait = serve(s.srv, listeners)
+	return nil
+}
+
+func (s *CallbackServer) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.srv != nil
+}
+
+func (s *CallbackServer) Close() error {
+	if s.srv == nil {
+		return nil
+	}
+	err := s.srv.Close()
+	s.wait()
+	return err
+}
+
+func (s *CallbackServer) dispatch(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	h, ok := s.flows[r.URL.Query().Get("state")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown state token", http.StatusBadRequest)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (s *CallbackServer) register(state string, h http.Handler) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flows[state] = h
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.flows, state)
+	}
+}
+
+// browserNoisePaths are requested by browsers on their own whenever a page
+// loads; they are answered without content instead of being treated as probes.
+var browserNoisePaths = map[string]bool{
+	"/favicon.ico":                      true,
+	"/apple-touch-icon.png":             true,
+	"/apple-touch-icon-precomposed.png": true,
+	"/robots.txt":                       true,
+}
+
+// callbackGuard only lets requests through that a browser following the
+// redirect URL would make, so that probes from other pages (e.g. through DNS
+// rebinding) never reach the flow.
+func callbackGuard(host, callbackPath string, next http.Handler) http.Handler {
+	if callbackPath == "" {
+		callbackPath = "/"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != callbackPath {
+			if browserNoisePaths[r.URL.Path] {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if host != "" && !strings.EqualFold(requestHostname(r), host) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxCallbackBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// implicitResponseParams only appear in implicit or hybrid flow responses,
+// which this package never requests.
+var implicitResponseParams = []string{"access_token", "id_token", "token_type", "expires_in"}
+
+// checkCallbackQuery accepts only the shape of an authorization code response:
+// single-valued parameters without control characters and no tokens.
+func checkCallbackQuery(rawQuery string) (url.Values, error) {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("malformed callback query: %v", err)
+	}
+	for _, param := range implicitResponseParams {
+		if query.Has(param) {
+			return nil, fmt.Errorf("unexpected %s parameter in callback; only authorization code responses are accepted", param)
+		}
+	}
+	for key, values := range query {
+		if len(values) > 1 {
+			return nil, fmt.Errorf("repeated %s parameter in callback", key)
+		}
+		if strings.IndexFunc(key+values[0], isControl) >= 0 {
+			return nil, fmt.Errorf("control character in callback parameter %s", key)
+		}
+	}
+	return query, nil
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+func requestHostname(r *http.Request) string {
+	if hostname, _, err := net.SplitHostPort(r.Host); err == nil {
+		return hostname
+	}
+	return strings.Trim(r.Host, "[]")
+}
+
+func splitRedirectURL(redirectURL string) (string, string, string, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to parse redirect URL: %v", err)
+	}
+
+	port := u.Port()
+	if port == "" {
+		if u.Scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+
+	return u.Hostname(), port, u.Path, nil
+}
+
+func newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		MaxHeaderBytes:    maxCallbackHeaderBytes,
+		ReadHeaderTimeout: callbackReadHeaderTimeout,
+	}
+}
+
+func serve(srv *http.Server, listeners []net.Listener) func() {
+	var wg sync.WaitGroup
+	for _, ln := range listeners {
+		ln = newLimitListener(ln, maxCallbackConns)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fmt.Fprintf(os.Stderr, "Starting server on %s\n", ln.Addr())
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+			}
+		}()
+	}
+	return wg.Wait
+}
+
+func shutdownServer(srv *http.Server, timeout time.Duration, wait func()) {
+	if err := srv.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Server close error: %v\n", err)
+	}
+
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if errShutdown := srv.Shutdown(ctxShutdown); errShutdown != nil && errShutdown != context.DeadlineExceeded {
+		fmt.Fprintf(os.Stderr, "Server shutdown error: %v\n", errShutdown)
+	}
+
+	wait()
+}
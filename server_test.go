@@ -0,0 +1,229 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckCallbackQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{name: "code response", query: "state=s&code=c&scope=email"},
+		{name: "error response", query: "state=s&error=access_denied"},
+		{name: "access_token", query: "state=s&code=c&access_token=at", wantErr: true},
+		{name: "id_token", query: "state=s&id_token=x", wantErr: true},
+		{name: "token_type", query: "state=s&code=c&token_type=Bearer", wantErr: true},
+		{name: "expires_in", query: "state=s&code=c&expires_in=3600", wantErr: true},
+		{name: "repeated code", query: "state=s&code=a&code=b", wantErr: true},
+		{name: "repeated state", query: "state=s&state=t&code=c", wantErr: true},
+		{name: "encoded null in code", query: "state=s&code=c%00d", wantErr: true},
+		{name: "encoded null in key", query: "state=s&co%00de=c", wantErr: true},
+		{name: "encoded newline", query: "state=s%0A&code=c", wantErr: true},
+		{name: "delete character", query: "state=s&code=c%7F", wantErr: true},
+		{name: "bad escape", query: "state=s&code=%zz", wantErr: true},
+		{name: "many repeated params", query: "state=s&code=c" + strings.Repeat("&x=1", 10000), wantErr: true},
+		{name: "long single value", query: "state=s&code=" + strings.Repeat("a", 64<<10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkCallbackQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkCallbackQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseManualCode(t *testing.T) {
+	o := New()
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "bare code", input: "4/0Abc", want: "4/0Abc"},
+		{name: "redirected URL", input: "http://localhost/callback?state=s&code=c", want: "c"},
+		{name: "empty", input: "", wantErr: true},
+		{name: "bare code with null", input: "4/0A\x00bc", wantErr: true},
+		{name: "wrong state", input: "http://localhost/callback?state=t&code=c", wantErr: true},
+		{name: "missing code", input: "http://localhost/callback?state=s", wantErr: true},
+		{name: "authorization error", input: "http://localhost/callback?state=s&error=access_denied", wantErr: true},
+		{name: "implicit params", input: "http://localhost/callback?state=s&code=c&access_token=at", wantErr: true},
+		{name: "repeated code", input: "http://localhost/callback?state=s&code=a&code=b", wantErr: true},
+		{name: "repeated state", input: "http://localhost/callback?state=s&state=s&code=c", wantErr: true},
+		{name: "encoded null", input: "http://localhost/callback?state=s&code=c%00", wantErr: true},
+		{name: "oversized query", input: "http://localhost/callback?state=s&code=c" + strings.Repeat("&x=1", 10000), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := o.parseManualCode(tt.input, "s")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseManualCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseManualCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallbackServerRejectsOversizedQuery(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reached atomic.Bool
+	srv := newHTTPServer(callbackGuard("127.0.0.1", "/callback", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached.Store(true)
+	})))
+	wait := serve(srv, []net.Listener{ln})
+	defer func() {
+		srv.Close()
+		wait()
+	}()
+
+	u := url.URL{Scheme: "http", Host: ln.Addr().String(), Path: "/callback", RawQuery: "state=s&code=" + strings.Repeat("a", 2*maxCallbackHeaderBytes)}
+	res, err := http.Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusRequestHeaderFieldsTooLarge)
+	}
+	if reached.Load() {
+		t.Error("oversized request reached the handler")
+	}
+}
+
+func TestCallbackGuard(t *testing.T) {
+	var reached atomic.Bool
+	srv := httptest.NewServer(callbackGuard("127.0.0.1", "/callback", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached.Store(true)
+	})))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		host       string
+		wantStatus int
+		wantReach  bool
+	}{
+		{name: "callback", method: http.MethodGet, path: "/callback?state=s&code=c", wantStatus: http.StatusOK, wantReach: true},
+		{name: "wrong path", method: http.MethodGet, path: "/other?state=s&code=c", wantStatus: http.StatusNotFound},
+		{name: "browser noise", method: http.MethodGet, path: "/favicon.ico", wantStatus: http.StatusNoContent},
+		{name: "POST", method: http.MethodPost, path: "/callback?state=s&code=c", wantStatus: http.StatusMethodNotAllowed},
+		{name: "PUT", method: http.MethodPut, path: "/callback?state=s&code=c", wantStatus: http.StatusMethodNotAllowed},
+		{name: "rebound host", method: http.MethodGet, path: "/callback?state=s&code=c", host: "attacker.example.com", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached.Store(false)
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusMethodNotAllowed && res.Header.Get("Allow") != http.MethodGet {
+				t.Errorf("Allow = %q, want GET", res.Header.Get("Allow"))
+			}
+			if reached.Load() != tt.wantReach {
+				t.Errorf("reached handler = %v, want %v", reached.Load(), tt.wantReach)
+			}
+		})
+	}
+}
+
+func TestCallbackRejectsDuplicateParamsAndReplay(t *testing.T) {
+	var exchanges atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"at","refresh_token":"rt","token_type":"Bearer","expires_in":3600,"scope":"email"}`)
+	}))
+	defer tokenServer.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirectURL := fmt.Sprintf("http://127.0.0.1:%d/callback", ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+	server := NewCallbackServer(redirectURL)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	dir := t.TempDir()
+	ready := make(chan string, 1)
+	o := New(
+		WithCredentialsPath(writeCredentials(t, dir, tokenServer.URL+"/token", redirectURL)),
+		WithTokenPath(filepath.Join(dir, "token.json")),
+		WithCallbackServer(server),
+		WithScopes([]string{"email"}),
+		WithReadyHook(func(authURL string) { ready <- authURL }),
+	)
+	get := func(query string) int {
+		res, err := http.Get(redirectURL + "?" + query)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	var valid string
+	statuses := make(chan [2]int, 1)
+	go func() {
+		u, _ := url.Parse(<-ready)
+		state := url.QueryEscape(u.Query().Get("state"))
+		valid = "state=" + state + "&code=c"
+		duplicate := get("state=" + state + "&state=" + state + "&code=c")
+		statuses <- [2]int{duplicate, get(valid)}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := o.Authenticate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := <-statuses
+	if got[0] != http.StatusBadRequest {
+		t.Errorf("duplicate state status = %d, want 400", got[0])
+	}
+	if got[1] != http.StatusOK {
+		t.Errorf("valid callback status = %d, want 200", got[1])
+	}
+	if status := get(valid); status != http.StatusBadRequest {
+		t.Errorf("replayed callback status = %d, want 400", status)
+	}
+	if n := exchanges.Load(); n != 1 {
+		t.Errorf("code exchanged %d times, want 1", n)
+	}
+}
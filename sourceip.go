@@ -0,0 +1,60 @@
+package googleoauth2callback
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+)
+
+var ErrForbiddenCallbackSource = errors.New("callback request from a source address that is not allowed")
+
+var loopbackPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("::1/128"),
+}
+
+// WithAllowedCallbackCIDRs makes the callback handler reject requests whose
+// peer address is neither loopback nor in one of prefixes, for setups where
+// the port may end up exposed, e.g. through Docker port publishing. Calling it
+// without prefixes allows loopback only. Requests over a Unix socket are not
+// affected, and X-Forwarded-For is never consulted. An invalid prefix, such as
+// the zero value left by an ignored netip.ParsePrefix error, makes
+// Authenticate fail instead of silently allowing less or more than intended.
+func WithAllowedCallbackCIDRs(prefixes ...netip.Prefix) Option {
+	return func(o *OAuth2Callback) {
+		o.callbackCIDRs = append(append([]netip.Prefix{}, loopbackPrefixes...), prefixes...)
+	}
+}
+
+func (o *OAuth2Callback) checkCallbackCIDRs() error {
+	for _, prefix := range o.callbackCIDRs {
+		if !prefix.IsValid() {
+			return fmt.Errorf("invalid allowed callback CIDR: %q", prefix)
+		}
+	}
+	return nil
+}
+
+func (o *OAuth2Callback) allowedCallbackSource(r *http.Request) bool {
+	if len(o.callbackCIDRs) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// Unix socket peers have no IP address.
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.WithZone("").Unmap()
+	for _, prefix := range o.callbackCIDRs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
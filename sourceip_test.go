@@ -0,0 +1,61 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestAllowedCallbackSource(t *testing.T) {
+	o := New(WithAllowedCallbackCIDRs(
+		netip.MustParsePrefix("172.17.0.0/16"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	))
+	tests := []struct {
+		remoteAddr string
+		want       bool
+	}{
+		{remoteAddr: "127.0.0.1:50000", want: true},
+		{remoteAddr: "127.8.9.10:50000", want: true},
+		{remoteAddr: "[::1]:50000", want: true},
+		{remoteAddr: "172.17.0.2:50000", want: true},
+		{remoteAddr: "[::ffff:172.17.0.2]:50000", want: true},
+		{remoteAddr: "[2001:db8::5]:50000", want: true},
+		{remoteAddr: "[fe80::1%eth0]:50000", want: false},
+		{remoteAddr: "172.18.0.2:50000", want: false},
+		{remoteAddr: "192.168.1.10:50000", want: false},
+		{remoteAddr: "[2001:db9::5]:50000", want: false},
+		{remoteAddr: "not-an-ip:50000", want: false},
+		{remoteAddr: "@", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remoteAddr}
+			if got := o.allowedCallbackSource(r); got != tt.want {
+				t.Errorf("allowedCallbackSource(%s) = %v, want %v", tt.remoteAddr, got, tt.want)
+			}
+		})
+	}
+
+	loopbackOnly := New(WithAllowedCallbackCIDRs())
+	if loopbackOnly.allowedCallbackSource(&http.Request{RemoteAddr: "172.17.0.2:50000"}) {
+		t.Error("WithAllowedCallbackCIDRs() without prefixes allowed a non-loopback address")
+	}
+	if !New().allowedCallbackSource(&http.Request{RemoteAddr: "192.168.1.10:50000"}) {
+		t.Error("a callback without an allowlist rejected a request")
+	}
+}
+
+func TestAllowedCallbackCIDRsRejectsInvalidPrefix(t *testing.T) {
+	malformed, _ := netip.ParsePrefix("10.0.0.0/33")
+	o := New(
+		WithAllowedCallbackCIDRs(malformed),
+		WithReadyHook(func(string) { t.Error("an authorization flow was started") }),
+	)
+	_, err := o.Authenticate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "invalid allowed callback CIDR") {
+		t.Errorf("Authenticate error = %v, want an invalid CIDR error", err)
+	}
+}
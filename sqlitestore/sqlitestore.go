@@ -0,0 +1,131 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+	"golang.org/x/oauth2"
+)
+
+const schema = `CREATE TABLE IF NOT EXISTS accounts (
+	key           TEXT PRIMARY KEY,
+	account_email TEXT NOT NULL DEFAULT '',
+	client_id     TEXT NOT NULL DEFAULT '',
+	scopes        TEXT NOT NULL DEFAULT '',
+	token         TEXT NOT NULL,
+	updated_at    TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS accounts_account_email ON accounts (account_email);
+CREATE INDEX IF NOT EXISTS accounts_client_id ON accounts (client_id);`
+
+const selectColumns = `SELECT key, account_email, client_id, scopes, token, updated_at FROM accounts`
+
+type Store struct {
+	db *sql.DB
+}
+
+func New(ctx context.Context, db *sql.DB) (*Store, error) {
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return nil, fmt.Errorf("failed to create accounts table: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Load(ctx context.Context, key string) (*googleoauth2callback.StoredToken, error) {
+	row := s.db.QueryRowContext(ctx, selectColumns+` WHERE key = ?`, key)
+	stored, err := scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, googleoauth2callback.ErrTokenNotFound
+	}
+	return stored, err
+}
+
+func (s *Store) Save(ctx context.Context, stored *googleoauth2callback.StoredToken) error {
+	tokenJSON, err := json.Marshal(stored.Token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO accounts (key, account_email, client_id, scopes, token, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (key) DO UPDATE SET
+	account_email = excluded.account_email,
+	client_id = excluded.client_id,
+	scopes = excluded.scopes,
+	token = excluded.token,
+	updated_at = excluded.updated_at`,
+		stored.Key, stored.AccountEmail, stored.ClientID, strings.Join(stored.Scopes, " "), string(tokenJSON), stored.UpdatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save token: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM accounts WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete token: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) List(ctx context.Context) ([]*googleoauth2callback.StoredToken, error) {
+	return s.query(ctx, selectColumns+` ORDER BY account_email, key`)
+}
+
+func (s *Store) FindByAccountEmail(ctx context.Context, email string) ([]*googleoauth2callback.StoredToken, error) {
+	return s.query(ctx, selectColumns+` WHERE account_email = ? ORDER BY key`, email)
+}
+
+func (s *Store) FindByClientID(ctx context.Context, clientID string) ([]*googleoauth2callback.StoredToken, error) {
+	return s.query(ctx, selectColumns+` WHERE client_id = ? ORDER BY account_email, key`, clientID)
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]*googleoauth2callback.StoredToken, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %v", err)
+	}
+	defer rows.Close()
+
+	var result []*googleoauth2callback.StoredToken
+	for rows.Next() {
+		stored, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, stored)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %v", err)
+	}
+	return result, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(row scanner) (*googleoauth2callback.StoredToken, error) {
+	var (
+		stored    googleoauth2callback.StoredToken
+		scopes    string
+		tokenJSON string
+		updatedAt time.Time
+	)
+	if err := row.Scan(&stored.Key, &stored.AccountEmail, &stored.ClientID, &scopes, &tokenJSON, &updatedAt); err != nil {
+		return nil, err
+	}
+	var tok oauth2.Token
+	if err := json.Unmarshal([]byte(tokenJSON), &tok); err != nil {
+		return nil, fmt.Errorf("failed to parse stored token: %v", err)
+	}
+	stored.Token = &tok
+	stored.Scopes = strings.Fields(scopes)
+	stored.UpdatedAt = updatedAt
+	return &stored, nil
+}
@@ -0,0 +1,113 @@
+package googleoauth2callback
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+type FlowPhase string
+
+const (
+	FlowPhaseIdle             FlowPhase = "idle"
+	FlowPhasePending          FlowPhase = "pending"
+	FlowPhaseAwaitingCallback FlowPhase = "awaiting_callback"
+	FlowPhaseDone             FlowPhase = "done"
+	FlowPhaseFailed           FlowPhase = "failed"
+)
+
+type FlowStatus struct {
+	Phase     FlowPhase   `json:"phase"`
+	AuthURL   string      `json:"auth_url,omitempty"`
+	StartedAt time.Time   `json:"started_at,omitzero"`
+	UpdatedAt time.Time   `json:"updated_at,omitzero"`
+	Error     string      `json:"error,omitempty"`
+	Timings   FlowTimings `json:"timings,omitzero"`
+}
+
+// FlowTimings records when each step of the last flow happened. Times of steps
+// the flow did not reach are zero.
+type FlowTimings struct {
+	URLPresentedAt     time.Time `json:"url_presented_at,omitzero"`
+	CallbackReceivedAt time.Time `json:"callback_received_at,omitzero"`
+	ExchangedAt        time.Time `json:"exchanged_at,omitzero"`
+}
+
+// ConsentDuration is the time the user took from being shown the URL to
+// returning to the callback.
+func (t FlowTimings) ConsentDuration() time.Duration {
+	if t.URLPresentedAt.IsZero() || t.CallbackReceivedAt.IsZero() {
+		return 0
+	}
+	return t.CallbackReceivedAt.Sub(t.URLPresentedAt)
+}
+
+func (t FlowTimings) ExchangeDuration() time.Duration {
+	if t.CallbackReceivedAt.IsZero() || t.ExchangedAt.IsZero() {
+		return 0
+	}
+	return t.ExchangedAt.Sub(t.CallbackReceivedAt)
+}
+
+func WithStateFile(path string) Option {
+	return func(o *OAuth2Callback) {
+		o.stateFilePath = path
+	}
+}
+
+func (o *OAuth2Callback) FlowStatus() FlowStatus {
+	o.statusMu.Lock()
+	defer o.statusMu.Unlock()
+	if o.status.Phase == "" {
+		return FlowStatus{Phase: FlowPhaseIdle}
+	}
+	return o.status
+}
+
+func (o *OAuth2Callback) setFlowPhase(phase FlowPhase, authURL string, flowErr error) {
+	o.statusMu.Lock()
+	now := o.now()
+	if phase == FlowPhasePending {
+		o.status = FlowStatus{StartedAt: now}
+	}
+	o.status.Phase = phase
+	o.status.UpdatedAt = now
+	if authURL != "" {
+		o.status.AuthURL = authURL
+	}
+	if flowErr != nil {
+		o.status.Error = flowErr.Error()
+	}
+	status := o.status
+	o.statusMu.Unlock()
+	o.persistFlowStatus(status)
+}
+
+// recordFlowTiming stamps one step of the current flow with the current time.
+func (o *OAuth2Callback) recordFlowTiming(set func(t *FlowTimings, now time.Time)) {
+	o.statusMu.Lock()
+	set(&o.status.Timings, o.now())
+	status := o.status
+	o.statusMu.Unlock()
+	o.persistFlowStatus(status)
+}
+
+func (o *OAuth2Callback) persistFlowStatus(status FlowStatus) {
+	if o.stateFilePath == "" {
+		return
+	}
+	if err := writeStateFile(o.stateFilePath, status); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write state file: %v\n", err)
+	}
+}
+
+// writeStateFile writes status privately, since its AuthURL carries the state
+// token and PKCE challenge of the flow in progress.
+func writeStateFile(path string, status FlowStatus) error {
+	b, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(path, b)
+}
@@ -0,0 +1,145 @@
+package googleoauth2callback
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+var ErrClientMismatch = errors.New("credentials changed since the token was issued")
+
+type StorageMode int
+
+const (
+	StorageModeFull StorageMode = iota
+	StorageModeRefreshTokenOnly
+	// StorageModeNone keeps the token in memory only. Nothing is written,
+	// including refresh token backups and pending flows for ResumeFlow.
+	StorageModeNone
+)
+
+type refreshTokenRecord struct {
+	Version      int    `json:"version"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	AccountEmail string `json:"account_email,omitempty"`
+
+	ClientFingerprint string `json:"client_fingerprint,omitempty"`
+}
+
+func WithAppName(name string) Option {
+	return func(o *OAuth2Callback) {
+		o.appName = name
+	}
+}
+
+func WithTokenNamespace(namespace string) Option {
+	return func(o *OAuth2Callback) {
+		o.tokenNamespace = namespace
+	}
+}
+
+// resolveTokenPath returns the explicitly configured token path, or derives one
+// from the namespace so that different clients and scope sets never share a
+// cached token.
+func (o *OAuth2Callback) resolveTokenPath() (string, error) {
+	if o.tokenPath != "" {
+		return o.tokenPath, nil
+	}
+	namespace, err := o.resolveTokenNamespace()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(o.tokenDir(), "token-"+namespace+".json"), nil
+}
+
+func (o *OAuth2Callback) resolveTokenNamespace() (string, error) {
+	if o.tokenNamespace != "" {
+		return o.tokenNamespace, nil
+	}
+	_, creds, err := o.readCredentials()
+	if err != nil {
+		return "", err
+	}
+	return tokenNamespace(creds.Web.ClientID, o.namespaceScopes()), nil
+}
+
+func (o *OAuth2Callback) tokenDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(configDir, o.appName)
+}
+
+func tokenNamespace(clientID string, scopes []string) string {
+	sorted := slices.Clone(scopes)
+	slices.Sort(sorted)
+	sum := sha256.Sum256([]byte(clientID + "\n" + strings.Join(sorted, " ")))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// clientFingerprint identifies an OAuth client in stored tokens without
+// spelling out the client ID.
+func clientFingerprint(clientID string) string {
+	sum := sha256.Sum256([]byte(clientID))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// checkClient fails when a cached token was issued to a different OAuth client
+// than the one in the current credentials, which the token endpoint would
+// only reject with invalid_client at refresh time.
+func (o *OAuth2Callback) checkClient(fingerprint string) error {
+	if fingerprint == "" {
+		return nil
+	}
+	_, creds, err := o.readCredentials()
+	if err != nil {
+		return nil
+	}
+	if fingerprint != clientFingerprint(creds.Web.ClientID) {
+		return fmt.Errorf("%w; the cached token belongs to a different OAuth client and a new sign-in is required", ErrClientMismatch)
+	}
+	return nil
+}
+
+func WithStorageMode(mode StorageMode) Option {
+	return func(o *OAuth2Callback) {
+		o.storageMode = mode
+	}
+}
+
+// tokenForStorage returns the value persisted for token. In refresh-token-only
+// mode the access token is dropped, so a loaded token is always invalid and the
+// token source mints a fresh access token in memory on first use.
+func (o *OAuth2Callback) tokenForStorage(token *oauth2.Token) (any, error) {
+	if o.storageMode != StorageModeRefreshTokenOnly {
+		file := newTokenFile(token, o.currentAccountEmail())
+		if _, creds, err := o.readCredentials(); err == nil {
+			file.ClientFingerprint = clientFingerprint(creds.Web.ClientID)
+		}
+		return file, nil
+	}
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("token has no refresh token to store")
+	}
+	record := refreshTokenRecord{
+		Version:      tokenFileVersion,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+		AccountEmail: o.currentAccountEmail(),
+	}
+	if _, creds, err := o.readCredentials(); err == nil {
+		record.ClientID = creds.Web.ClientID
+		record.ClientFingerprint = clientFingerprint(creds.Web.ClientID)
+	}
+	return record, nil
+}
@@ -0,0 +1,45 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestStorageModeNoneWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	credentialsPath := filepath.Join(dir, "credentials.json")
+	creds := `{"web":{"client_id":"cid","client_secret":"sec","redirect_uris":["http://localhost:8080/callback"]}}`
+	if err := os.WriteFile(credentialsPath, []byte(creds), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o := New(
+		WithCredentialsPath(credentialsPath),
+		WithRedirectURL("http://localhost:8080/callback"),
+		WithStorageMode(StorageModeNone),
+	)
+	ctx := context.Background()
+	if err := o.saveToken(ctx, &oauth2.Token{AccessToken: "at", RefreshToken: "rt", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	o.rotateRefreshToken(ctx, "old", &oauth2.Token{AccessToken: "at2", RefreshToken: "rt2"})
+	if _, _, err := o.BuildAuthURL(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "credentials.json" {
+			t.Errorf("unexpected file written: %s", e.Name())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package googleoauth2callback
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+const openerMessageType = "googleoauth2callback:complete"
+
+var openerSuccessPage = template.Must(template.New("success").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Message}}</title></head>
+<body>
+<p>{{.Message}}</p>
+<script nonce="{{.Nonce}}">
+if (window.opener) {
+  window.opener.postMessage({type: {{.Type}}, success: true}, {{.TargetOrigin}});
+}
+window.close();
+</script>
+</body>
+</html>
+`))
+
+func WithOpenerMessage(targetOrigin string) Option {
+	return func(o *OAuth2Callback) {
+		o.openerOrigin = targetOrigin
+	}
+}
+
+func (o *OAuth2Callback) writeSuccess(w http.ResponseWriter, r *http.Request) {
+	if o.openerOrigin == "" {
+		fmt.Fprint(w, o.message(r, msgSuccess))
+		return
+	}
+	nonce, err := generateStateToken()
+	if err != nil {
+		fmt.Fprint(w, o.message(r, msgSuccess))
+		return
+	}
+	// The page's inline script has to be allowed by the security policy.
+	if csp := w.Header().Get("Content-Security-Policy"); csp != "" {
+		w.Header().Set("Content-Security-Policy", csp+"; script-src 'nonce-"+nonce+"'")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	openerSuccessPage.Execute(w, struct {
+		Message      string
+		Type         string
+		TargetOrigin string
+		Nonce        string
+	}{
+		Message:      o.message(r, msgSuccess),
+		Type:         openerMessageType,
+		TargetOrigin: o.openerOrigin,
+		Nonce:        nonce,
+	})
+}
@@ -0,0 +1,98 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/oauth2"
+)
+
+const instrumentationName = "github.com/yuya-takeyama/googleoauth2callback"
+
+type telemetry struct {
+	tracer         trace.Tracer
+	authSuccesses  metric.Int64Counter
+	authFailures   metric.Int64Counter
+	refreshLatency metric.Float64Histogram
+}
+
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *OAuth2Callback) {
+		o.tracerProvider = provider
+	}
+}
+
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *OAuth2Callback) {
+		o.meterProvider = provider
+	}
+}
+
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider) *telemetry {
+	if tp == nil {
+		tp = tracenoop.NewTracerProvider()
+	}
+	if mp == nil {
+		mp = metricnoop.NewMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+
+	t := &telemetry{tracer: tp.Tracer(instrumentationName)}
+	// Instrument creation only fails on invalid names, in which case the
+	// returned instrument is a usable no-op.
+	t.authSuccesses, _ = meter.Int64Counter("googleoauth2callback.auth.successes",
+		metric.WithDescription("Number of completed interactive authentications"))
+	t.authFailures, _ = meter.Int64Counter("googleoauth2callback.auth.failures",
+		metric.WithDescription("Number of failed interactive authentications"))
+	t.refreshLatency, _ = meter.Float64Histogram("googleoauth2callback.token.refresh.duration",
+		metric.WithDescription("Duration of token refreshes"),
+		metric.WithUnit("s"))
+	return t
+}
+
+func (t *telemetry) start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, name)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *telemetry) recordAuth(ctx context.Context, err error) {
+	if err != nil {
+		t.authFailures.Add(ctx, 1)
+		return
+	}
+	t.authSuccesses.Add(ctx, 1)
+}
+
+type instrumentedTokenSource struct {
+	ctx       context.Context
+	src       oauth2.TokenSource
+	telemetry *telemetry
+	metrics   *metrics
+}
+
+func (s *instrumentedTokenSource) Token() (*oauth2.Token, error) {
+	_, span := s.telemetry.start(s.ctx, "googleoauth2callback.refresh")
+	start := time.Now()
+	tok, err := s.src.Token()
+	s.telemetry.refreshLatency.Record(s.ctx, time.Since(start).Seconds(),
+		metric.WithAttributes(attribute.Bool("error", err != nil)))
+	s.metrics.observeRefresh(err)
+	if err == nil {
+		s.metrics.setTokenExpiry(tok.Expiry)
+	}
+	endSpan(span, err)
+	return tok, err
+}
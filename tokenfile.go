@@ -0,0 +1,108 @@
+package googleoauth2callback
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// Token file format versions. Version 1 is the plain oauth2.Token JSON written
+// by earlier releases, without a version field. Version 2 adds the version and
+// the scope and id_token response fields.
+const tokenFileVersion = 2
+
+// tokenFile is the on-disk token format. Besides what oauth2.Token keeps it
+// records the token response fields oauth2 only exposes through Extra.
+type tokenFile struct {
+	Version int `json:"version,omitempty"`
+	*oauth2.Token
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	AccountEmail string `json:"account_email,omitempty"`
+
+	ClientFingerprint string `json:"client_fingerprint,omitempty"`
+}
+
+// decodeTokenFile parses any known version of the token file and reports
+// whether it is older than the current format and should be rewritten.
+func decodeTokenFile(b []byte) (*tokenFile, bool, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, false, err
+	}
+	version := header.Version
+	if version == 0 {
+		version = 1
+	}
+	if version > tokenFileVersion {
+		return nil, false, fmt.Errorf("token file format version %d is newer than the supported version %d", version, tokenFileVersion)
+	}
+	file := tokenFile{Token: &oauth2.Token{}}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, false, err
+	}
+	return &file, version < tokenFileVersion, nil
+}
+
+func newTokenFile(tok *oauth2.Token, accountEmail string) *tokenFile {
+	response := RawTokenResponse(tok)
+	return &tokenFile{
+		Version:      tokenFileVersion,
+		Token:        tok,
+		Scope:        response.Scope,
+		IDToken:      response.IDToken,
+		AccountEmail: accountEmail,
+	}
+}
+
+// token returns the stored token with the recorded response fields available
+// through Extra again.
+func (f *tokenFile) token() *oauth2.Token {
+	extra := map[string]any{}
+	if f.Scope != "" {
+		extra["scope"] = f.Scope
+	}
+	if f.IDToken != "" {
+		extra["id_token"] = f.IDToken
+	}
+	if len(extra) == 0 {
+		return f.Token
+	}
+	return f.Token.WithExtra(extra)
+}
+
+type TokenResponse struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	ExpiresIn    int64
+	Scope        string
+	IDToken      string
+}
+
+// RawTokenResponse returns the fields of the token endpoint response tok was
+// created from, including those oauth2.Token only keeps in Extra.
+func RawTokenResponse(tok *oauth2.Token) TokenResponse {
+	response := TokenResponse{
+		AccessToken:  tok.AccessToken,
+		TokenType:    tok.TokenType,
+		RefreshToken: tok.RefreshToken,
+		ExpiresIn:    tok.ExpiresIn,
+	}
+	if scope, ok := tok.Extra("scope").(string); ok {
+		response.Scope = scope
+	}
+	if idToken, ok := tok.Extra("id_token").(string); ok {
+		response.IDToken = idToken
+	}
+	switch expiresIn := tok.Extra("expires_in").(type) {
+	case float64:
+		response.ExpiresIn = int64(expiresIn)
+	case int64:
+		response.ExpiresIn = expiresIn
+	}
+	return response
+}
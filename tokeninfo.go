@@ -0,0 +1,71 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+type TokenInfo struct {
+	Audience        string
+	AuthorizedParty string
+	Subject         string
+	Scopes          []string
+	Expiry          time.Time
+	Email           string
+	EmailVerified   bool
+}
+
+// IntrospectToken asks Google's tokeninfo endpoint what the cached access
+// token is for. Like AccessToken, it refreshes the token first if needed but
+// fails with ErrInteractiveAuthRequired rather than start a new authorization
+// flow.
+func (o *OAuth2Callback) IntrospectToken(ctx context.Context) (*TokenInfo, error) {
+	tok, err := o.AccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return o.introspect(ctx, tok.AccessToken)
+}
+
+func (o *OAuth2Callback) introspect(ctx context.Context, accessToken string) (*TokenInfo, error) {
+	// The token goes in the body rather than the query, so that it does not
+	// end up in proxy or debug logs.
+	form := url.Values{"access_token": {accessToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenInfoURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var res struct {
+		Audience        string `json:"aud"`
+		AuthorizedParty string `json:"azp"`
+		Subject         string `json:"sub"`
+		Scope           string `json:"scope"`
+		Exp             string `json:"exp"`
+		Email           string `json:"email"`
+		EmailVerified   string `json:"email_verified"`
+	}
+	if err := doJSON(contextClient(o.tokenEndpointContext(ctx)), req, &res); err != nil {
+		return nil, fmt.Errorf("failed to introspect token: %v", err)
+	}
+
+	info := &TokenInfo{
+		Audience:        res.Audience,
+		AuthorizedParty: res.AuthorizedParty,
+		Subject:         res.Subject,
+		Scopes:          strings.Fields(res.Scope),
+		Email:           res.Email,
+		EmailVerified:   res.EmailVerified == "true",
+	}
+	if exp, err := strconv.ParseInt(res.Exp, 10, 64); err == nil {
+		info.Expiry = time.Unix(exp, 0)
+	}
+	return info, nil
+}
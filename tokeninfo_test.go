@@ -0,0 +1,47 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestIntrospectSendsTokenInBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.RawQuery != "" {
+			t.Errorf("request = %s %s, want POST without query", r.Method, r.URL)
+		}
+		if got := r.PostFormValue("access_token"); got != "at" {
+			t.Errorf("access_token = %q, want at", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"aud":"cid","scope":"email","exp":"1900000000","email":"ops@example.com","email_verified":"true"}`)
+	}))
+	defer srv.Close()
+
+	o := New(WithTokenEndpointClient(&http.Client{Transport: rewriteTransport{srv.URL}}))
+	info, err := o.introspect(context.Background(), "at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Email != "ops@example.com" || !info.EmailVerified || info.Expiry.Unix() != 1900000000 {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestIntrospectTokenNeverStartsFlow(t *testing.T) {
+	dir := t.TempDir()
+	o := New(
+		WithCredentialsPath(writeCredentials(t, dir, "http://127.0.0.1:1/token", "http://localhost:8080/callback")),
+		WithTokenPath(filepath.Join(dir, "token.json")),
+		WithRedirectURL("http://localhost:8080/callback"),
+		WithReadyHook(func(string) { t.Error("an authorization flow was started") }),
+	)
+	if _, err := o.IntrospectToken(context.Background()); !errors.Is(err, ErrInteractiveAuthRequired) {
+		t.Errorf("IntrospectToken error = %v, want ErrInteractiveAuthRequired", err)
+	}
+}
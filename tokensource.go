@@ -0,0 +1,73 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// refreshingTokenSource refreshes on every call. Unlike config.TokenSource it
+// keeps no cache of its own, so the caching source wrapping it fully decides
+// when a token counts as expired.
+type refreshingTokenSource struct {
+	ctx                  context.Context
+	config               *oauth2.Config
+	mu                   sync.Mutex
+	refreshToken         string
+	previousRefreshToken string
+	onRotate             func(previous string, tok *oauth2.Token)
+	onRefresh            func(refreshToken string)
+	onError              func(err error, last *oauth2.Token, refreshToken string)
+	last                 *oauth2.Token
+	logf                 func(format string, args ...any)
+}
+
+func (s *refreshingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logf("refreshing access token")
+	tok, err := s.refresh(s.refreshToken)
+	if err != nil && isInvalidGrant(err) && s.previousRefreshToken != "" && s.previousRefreshToken != s.refreshToken {
+		s.logf("refresh token rejected, retrying with the previous refresh token")
+		if tok, err = s.refresh(s.previousRefreshToken); err == nil {
+			s.refreshToken = s.previousRefreshToken
+		}
+	}
+	if err != nil {
+		s.logf("token refresh failed: %v", err)
+		if s.onError != nil {
+			s.onError(newRefreshError(err), s.last, s.refreshToken)
+		}
+		return nil, err
+	}
+	s.last = tok
+	if tok.RefreshToken != "" && tok.RefreshToken != s.refreshToken {
+		previous := s.refreshToken
+		s.previousRefreshToken = previous
+		s.refreshToken = tok.RefreshToken
+		if s.onRotate != nil {
+			s.onRotate(previous, tok)
+		}
+	} else if s.onRefresh != nil {
+		s.onRefresh(s.refreshToken)
+	}
+	return tok, nil
+}
+
+func (s *refreshingTokenSource) setConfig(config *oauth2.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+func (s *refreshingTokenSource) refresh(refreshToken string) (*oauth2.Token, error) {
+	return s.config.TokenSource(s.ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+}
+
+func WithExpiryLeeway(leeway time.Duration) Option {
+	return func(o *OAuth2Callback) {
+		o.expiryLeeway = leeway
+	}
+}
@@ -0,0 +1,80 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshingTokenSourceFallsBackToPreviousRefreshToken(t *testing.T) {
+	var mu sync.Mutex
+	var attempts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshToken := r.PostFormValue("refresh_token")
+		mu.Lock()
+		attempts = append(attempts, refreshToken)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch refreshToken {
+		case "rt1":
+			w.Write([]byte(`{"access_token":"at-rt1","token_type":"Bearer","expires_in":3600}`))
+		case "rt2":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"internal_failure"}`))
+		}
+	}))
+	defer srv.Close()
+
+	var refreshed, rotated []string
+	s := &refreshingTokenSource{
+		ctx: context.Background(),
+		config: &oauth2.Config{
+			ClientID: "client-id",
+			Endpoint: oauth2.Endpoint{TokenURL: srv.URL, AuthStyle: oauth2.AuthStyleInParams},
+		},
+		refreshToken:         "rt2",
+		previousRefreshToken: "rt1",
+		onRotate:             func(previous string, tok *oauth2.Token) { rotated = append(rotated, previous) },
+		onRefresh:            func(refreshToken string) { refreshed = append(refreshed, refreshToken) },
+		logf:                 func(string, ...any) {},
+	}
+
+	tok, err := s.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if tok.AccessToken != "at-rt1" {
+		t.Errorf("AccessToken = %q, want at-rt1", tok.AccessToken)
+	}
+	if s.refreshToken != "rt1" {
+		t.Errorf("refreshToken = %q, want rt1", s.refreshToken)
+	}
+	if len(attempts) != 2 || attempts[0] != "rt2" || attempts[1] != "rt1" {
+		t.Errorf("refresh attempts = %v, want [rt2 rt1]", attempts)
+	}
+	if len(rotated) != 0 {
+		t.Errorf("onRotate called for %v, want no rotation", rotated)
+	}
+	if len(refreshed) != 1 || refreshed[0] != "rt1" {
+		t.Errorf("onRefresh called with %v, want [rt1]", refreshed)
+	}
+
+	attempts = nil
+	s.refreshToken, s.previousRefreshToken = "rt3", "rt1"
+	if _, err := s.Token(); err == nil {
+		t.Fatal("Token() with a failing endpoint succeeded")
+	}
+	if len(attempts) != 1 {
+		t.Errorf("refresh attempts = %v, want no fallback for errors other than invalid_grant", attempts)
+	}
+	if s.refreshToken != "rt3" {
+		t.Errorf("refreshToken = %q after a failed refresh, want rt3", s.refreshToken)
+	}
+}
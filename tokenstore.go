@@ -0,0 +1,83 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+type StoredToken struct {
+	Key          string        `json:"key"`
+	AccountEmail string        `json:"account_email,omitempty"`
+	ClientID     string        `json:"client_id,omitempty"`
+	Scopes       []string      `json:"scopes,omitempty"`
+	Token        *oauth2.Token `json:"token"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+type TokenStore interface {
+	Load(ctx context.Context, key string) (*StoredToken, error)
+	Save(ctx context.Context, token *StoredToken) error
+	Delete(ctx context.Context, key string) error
+}
+
+func WithTokenStore(store TokenStore) Option {
+	return func(o *OAuth2Callback) {
+		o.tokenStore = store
+	}
+}
+
+func (o *OAuth2Callback) loadStoredToken(ctx context.Context) (*oauth2.Token, error) {
+	key, err := o.resolveTokenNamespace()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := o.tokenStore.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if stored.Token == nil {
+		return nil, ErrTokenNotFound
+	}
+	if stored.ClientID != "" {
+		if err := o.checkClient(clientFingerprint(stored.ClientID)); err != nil {
+			o.warn(err)
+			return nil, err
+		}
+	}
+	if stored.AccountEmail != "" {
+		o.setAccountEmail(stored.AccountEmail)
+	}
+	return stored.Token, nil
+}
+
+func (o *OAuth2Callback) saveStoredToken(ctx context.Context, token *oauth2.Token) error {
+	key, err := o.resolveTokenNamespace()
+	if err != nil {
+		return err
+	}
+	if o.storageMode == StorageModeRefreshTokenOnly {
+		if token.RefreshToken == "" {
+			return errors.New("token has no refresh token to store")
+		}
+		token = &oauth2.Token{
+			RefreshToken: token.RefreshToken,
+			TokenType:    token.TokenType,
+		}
+	}
+	stored := &StoredToken{
+		Key:          key,
+		AccountEmail: o.currentAccountEmail(),
+		Scopes:       o.scopes,
+		Token:        token,
+		UpdatedAt:    o.now(),
+	}
+	if _, creds, err := o.readCredentials(); err == nil {
+		stored.ClientID = creds.Web.ClientID
+	}
+	return o.tokenStore.Save(ctx, stored)
+}
@@ -0,0 +1,117 @@
+package googleoauth2callback
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+const tunnelStartTimeout = 30 * time.Second
+
+// Tunnel exposes the local callback server on a public URL, for flows run in
+// containers or remote machines the browser cannot reach directly.
+type Tunnel interface {
+	// Start exposes localPort and returns the public base URL, without a path.
+	Start(ctx context.Context, localPort string) (string, error)
+	Close() error
+}
+
+// WithTunnel routes the callback through t. The public URL the tunnel gets
+// must be registered as a redirect URI, so use a tunnel with a reserved domain.
+func WithTunnel(t Tunnel) Option {
+	return func(o *OAuth2Callback) {
+		o.tunnel = t
+	}
+}
+
+// CloudflaredTunnel runs the named cloudflared tunnel, whose public hostname
+// has to be routed to it beforehand (cloudflared tunnel route dns name
+// hostname). Quick tunnels are not supported: their random trycloudflare.com
+// host can never match a registered redirect URI.
+func CloudflaredTunnel(name, hostname string) Tunnel {
+	return &commandTunnel{
+		name: "cloudflared",
+		args: func(port string) []string {
+			return []string{"tunnel", "run", "--url", "http://localhost:" + port, name}
+		},
+		pattern:   regexp.MustCompile(`Registered tunnel connection`),
+		publicURL: "https://" + hostname,
+	}
+}
+
+// NgrokTunnel starts an ngrok HTTP tunnel. Pass e.g. "--url=mytool.ngrok.app"
+// in args to use a reserved domain.
+func NgrokTunnel(args ...string) Tunnel {
+	return &commandTunnel{
+		name: "ngrok",
+		args: func(port string) []string {
+			return append([]string{"http", port, "--log", "stdout"}, args...)
+		},
+		pattern: regexp.MustCompile(`url=(https://\S+)`),
+	}
+}
+
+// commandTunnel runs a tunnel client and reads the public URL from its output.
+// With publicURL set, pattern only signals that the tunnel is up.
+type commandTunnel struct {
+	name      string
+	args      func(port string) []string
+	pattern   *regexp.Regexp
+	publicURL string
+	cmd       *exec.Cmd
+	output    *io.PipeWriter
+}
+
+func (t *commandTunnel) Start(ctx context.Context, localPort string) (string, error) {
+	pr, pw := io.Pipe()
+	t.cmd = exec.Command(t.name, t.args(localPort)...)
+	t.cmd.Stdout = pw
+	t.cmd.Stderr = pw
+	t.output = pw
+	if err := t.cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start %s: %v", t.name, err)
+	}
+
+	found := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			m := t.pattern.FindStringSubmatch(scanner.Text())
+			if m == nil {
+				continue
+			}
+			publicURL := m[len(m)-1]
+			if t.publicURL != "" {
+				publicURL = t.publicURL
+			}
+			select {
+			case found <- publicURL:
+			default:
+			}
+		}
+		io.Copy(io.Discard, pr)
+	}()
+
+	select {
+	case publicURL := <-found:
+		return publicURL, nil
+	case <-time.After(tunnelStartTimeout):
+	case <-ctx.Done():
+	}
+	t.Close()
+	return "", fmt.Errorf("%s did not come up", t.name)
+}
+
+func (t *commandTunnel) Close() error {
+	if t.cmd == nil || t.cmd.Process == nil {
+		return nil
+	}
+	t.cmd.Process.Kill()
+	t.cmd.Wait()
+	t.cmd = nil
+	return t.output.Close()
+}
@@ -0,0 +1,43 @@
+package googleoauth2callback
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// WithUserAgent sets the User-Agent sent to the token endpoint and by the
+// clients returned from GetClient and friends.
+func WithUserAgent(userAgent string) Option {
+	return func(o *OAuth2Callback) {
+		o.userAgent = userAgent
+	}
+}
+
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
+
+func (o *OAuth2Callback) withUserAgent(base http.RoundTripper) http.RoundTripper {
+	if o.userAgent == "" {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &userAgentTransport{base: base, userAgent: o.userAgent}
+}
+
+func (o *OAuth2Callback) newClient(ctx context.Context, ts oauth2.TokenSource) *http.Client {
+	client := oauth2.NewClient(o.proxyContext(ctx), ts)
+	client.Transport = o.withUserAgent(o.withRetry(client.Transport))
+	return client
+}
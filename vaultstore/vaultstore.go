@@ -0,0 +1,148 @@
+package vaultstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yuya-takeyama/googleoauth2callback"
+)
+
+type Store struct {
+	address    string
+	token      string
+	namespace  string
+	mount      string
+	pathPrefix string
+	client     *http.Client
+}
+
+type Option func(*Store)
+
+func WithMount(mount string) Option {
+	return func(s *Store) {
+		s.mount = mount
+	}
+}
+
+func WithPathPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.pathPrefix = prefix
+	}
+}
+
+func WithNamespace(namespace string) Option {
+	return func(s *Store) {
+		s.namespace = namespace
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Store) {
+		s.client = client
+	}
+}
+
+func New(address, token string, opts ...Option) *Store {
+	store := &Store{
+		address:    strings.TrimRight(address, "/"),
+		token:      token,
+		mount:      "secret",
+		pathPrefix: "googleoauth2callback",
+		client:     http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	return store
+}
+
+func (s *Store) Load(ctx context.Context, key string) (*googleoauth2callback.StoredToken, error) {
+	res, err := s.do(ctx, http.MethodGet, "data", key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, googleoauth2callback.ErrTokenNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError("read", res)
+	}
+
+	var body struct {
+		Data struct {
+			Data googleoauth2callback.StoredToken `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse Vault response: %v", err)
+	}
+	if body.Data.Data.Token == nil {
+		return nil, googleoauth2callback.ErrTokenNotFound
+	}
+	return &body.Data.Data, nil
+}
+
+func (s *Store) Save(ctx context.Context, stored *googleoauth2callback.StoredToken) error {
+	payload, err := json.Marshal(map[string]any{"data": stored})
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+	res, err := s.do(ctx, http.MethodPost, "data", stored.Key, payload)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return responseError("write", res)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, "metadata", key, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotFound {
+		return responseError("delete", res)
+	}
+	return nil
+}
+
+func (s *Store) do(ctx context.Context, method, kind, key string, body []byte) (*http.Response, error) {
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s/%s", s.address, s.mount, kind, s.pathPrefix, url.PathEscape(key))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Vault request failed: %v", err)
+	}
+	return res, nil
+}
+
+func responseError(op string, res *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	return fmt.Errorf("failed to %s Vault secret: %s: %s", op, res.Status, strings.TrimSpace(string(b)))
+}
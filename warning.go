@@ -0,0 +1,32 @@
+package googleoauth2callback
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrMissingRefreshToken = errors.New("token has no refresh token")
+
+func WithWarningHandler(handler func(err error)) Option {
+	return func(o *OAuth2Callback) {
+		o.warningHandler = handler
+	}
+}
+
+func (o *OAuth2Callback) warn(err error) {
+	if o.warningHandler != nil {
+		o.warningHandler(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+}
+
+// Google only issues a refresh token when the user is shown the consent
+// screen, so a token without one stops working once the access token expires.
+func (o *OAuth2Callback) missingRefreshTokenError() error {
+	if o.prompt != "consent" {
+		return fmt.Errorf("%w; the access token cannot be renewed after it expires (authenticate with WithPrompt(\"consent\") to obtain one)", ErrMissingRefreshToken)
+	}
+	return fmt.Errorf("%w; the access token cannot be renewed after it expires", ErrMissingRefreshToken)
+}
@@ -0,0 +1,166 @@
+package googleoauth2callback
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	webFlowStateTTL    = 10 * time.Minute
+	webFlowStateCookie = "googleoauth2callback_state"
+	// webFlowMaxPending bounds the states kept for unfinished logins; the
+	// oldest is dropped first, so a flood of login requests cannot grow
+	// memory without limit.
+	webFlowMaxPending = 10000
+)
+
+// WebFlow runs the authorization code flow inside a web application instead
+// of a local callback server. Mount LoginHandler and CallbackHandler on the
+// application's mux; the redirect URL must point at CallbackHandler.
+type WebFlow struct {
+	callback *OAuth2Callback
+	onToken  func(w http.ResponseWriter, r *http.Request, token *oauth2.Token) error
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+	order   []string
+}
+
+// WebFlow returns a web application flow. onToken receives each obtained
+// token, typically to store it in the user's session, and writes the
+// response, e.g. a redirect back into the application.
+func (o *OAuth2Callback) WebFlow(onToken func(w http.ResponseWriter, r *http.Request, token *oauth2.Token) error) *WebFlow {
+	return &WebFlow{
+		callback: o,
+		onToken:  onToken,
+		pending:  make(map[string]time.Time),
+	}
+}
+
+func (f *WebFlow) LoginHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		o := f.callback
+		config, err := o.createOAuth2Config()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			o.warn(fmt.Errorf("failed to create OAuth2 config: %w", err))
+			return
+		}
+		state, err := o.stateGenerator()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			o.warn(fmt.Errorf("failed to generate state token: %v", err))
+			return
+		}
+		f.addPending(state)
+		http.SetCookie(w, &http.Cookie{
+			Name:     webFlowStateCookie,
+			Value:    state,
+			Path:     "/",
+			MaxAge:   int(webFlowStateTTL.Seconds()),
+			Secure:   strings.HasPrefix(config.RedirectURL, "https://"),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, config.AuthCodeURL(state, o.authCodeOptions()...), http.StatusFound)
+	}
+}
+
+func (f *WebFlow) CallbackHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		o := f.callback
+		query, err := checkCallbackQuery(r.URL.RawQuery)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			o.warn(fmt.Errorf("ignoring callback request: %v", err))
+			return
+		}
+		state := query.Get("state")
+		// The state must also come back in the cookie set by LoginHandler, so
+		// only the browser that started the flow can complete it.
+		cookie, err := r.Cookie(webFlowStateCookie)
+		http.SetCookie(w, &http.Cookie{Name: webFlowStateCookie, Path: "/", MaxAge: -1})
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 || !f.takePending(state) {
+			http.Error(w, o.message(r, msgInvalidState), http.StatusBadRequest)
+			return
+		}
+		if errCode := query.Get("error"); errCode != "" {
+			http.Error(w, o.message(r, msgCodeNotFound), http.StatusBadRequest)
+			return
+		}
+		code := query.Get("code")
+		if code == "" {
+			http.Error(w, o.message(r, msgCodeNotFound), http.StatusBadRequest)
+			return
+		}
+
+		config, err := o.createOAuth2Config()
+		if err != nil {
+			http.Error(w, o.message(r, msgExchangeFailed), http.StatusInternalServerError)
+			o.warn(fmt.Errorf("failed to create OAuth2 config: %w", err))
+			return
+		}
+		tok, err := o.exchange(r.Context(), config, code)
+		if err != nil {
+			http.Error(w, o.message(r, msgExchangeFailed), http.StatusInternalServerError)
+			o.warn(fmt.Errorf("failed to exchange token: %v", err))
+			return
+		}
+		if err := verifyGrantedScopes(config.Scopes, tok); err != nil {
+			http.Error(w, o.message(r, msgScopesNotGranted), http.StatusForbidden)
+			return
+		}
+		if err := f.onToken(w, r, tok); err != nil {
+			http.Error(w, o.message(r, msgWriteTokenFailed), http.StatusInternalServerError)
+			o.warn(fmt.Errorf("failed to handle token: %v", err))
+		}
+	}
+}
+
+func (f *WebFlow) addPending(state string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.callback.now()
+	// States expire in the order they were issued, so expired, used and
+	// evicted ones are all dropped from the front.
+	for len(f.order) > 0 {
+		expiry, ok := f.pending[f.order[0]]
+		if ok && !now.After(expiry) && len(f.pending) < webFlowMaxPending {
+			break
+		}
+		delete(f.pending, f.order[0])
+		f.order = f.order[1:]
+	}
+	// Used states stay in order until they reach the front; compact it when
+	// they pile up.
+	if len(f.order) > 2*webFlowMaxPending {
+		order := make([]string, 0, len(f.pending)+1)
+		for _, s := range f.order {
+			if _, ok := f.pending[s]; ok {
+				order = append(order, s)
+			}
+		}
+		f.order = order
+	}
+	f.pending[state] = now.Add(webFlowStateTTL)
+	f.order = append(f.order, state)
+}
+
+// takePending reports whether state was issued by LoginHandler and has not
+// expired or been used yet.
+func (f *WebFlow) takePending(state string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	expiry, ok := f.pending[state]
+	if !ok {
+		return false
+	}
+	delete(f.pending, state)
+	return f.callback.now().Before(expiry)
+}
@@ -0,0 +1,114 @@
+package googleoauth2callback
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestWebFlowPendingIsBounded(t *testing.T) {
+	now := time.Now()
+	o := New(WithClock(func() time.Time { return now }))
+	f := o.WebFlow(nil)
+
+	for i := range webFlowMaxPending + 100 {
+		f.addPending(fmt.Sprintf("state-%d", i))
+	}
+	if len(f.pending) > webFlowMaxPending {
+		t.Errorf("pending = %d, want at most %d", len(f.pending), webFlowMaxPending)
+	}
+	if f.takePending("state-0") {
+		t.Error("oldest state was not evicted")
+	}
+	if !f.takePending(fmt.Sprintf("state-%d", webFlowMaxPending+99)) {
+		t.Error("newest state was evicted")
+	}
+
+	// Used states do not make order grow without bound.
+	for i := range 3 * webFlowMaxPending {
+		state := fmt.Sprintf("used-%d", i)
+		f.addPending(state)
+		f.takePending(state)
+	}
+	if len(f.order) > 2*webFlowMaxPending+1 {
+		t.Errorf("order = %d entries", len(f.order))
+	}
+
+	now = now.Add(webFlowStateTTL + time.Second)
+	f.addPending("fresh")
+	if len(f.pending) != 1 {
+		t.Errorf("pending after expiry = %d, want 1", len(f.pending))
+	}
+}
+
+func TestWebFlowCallbackRequiresStateCookie(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"at","token_type":"Bearer","expires_in":3600,"scope":"email"}`)
+	}))
+	defer tokenServer.Close()
+
+	dir := t.TempDir()
+	redirectURL := "https://app.example.com/oauth2/callback"
+	o := New(
+		WithCredentialsPath(writeCredentials(t, dir, tokenServer.URL+"/token", redirectURL)),
+		WithTokenPath(filepath.Join(dir, "token.json")),
+		WithRedirectURL(redirectURL),
+		WithScopes([]string{"email"}),
+	)
+	var tokens int
+	f := o.WebFlow(func(w http.ResponseWriter, r *http.Request, tok *oauth2.Token) error {
+		tokens++
+		return nil
+	})
+
+	login := func() (string, *http.Cookie) {
+		rec := httptest.NewRecorder()
+		f.LoginHandler()(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+		loc, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != webFlowStateCookie || !cookies[0].HttpOnly || !cookies[0].Secure {
+			t.Fatalf("login cookies = %+v", cookies)
+		}
+		return loc.Query().Get("state"), cookies[0]
+	}
+	callback := func(state string, cookie *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/callback?"+url.Values{"state": {state}, "code": {"c"}}.Encode(), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		f.CallbackHandler()(rec, req)
+		return rec.Code
+	}
+
+	state, cookie := login()
+	_, otherCookie := login()
+	if code := callback(state, nil); code != http.StatusBadRequest {
+		t.Errorf("callback without cookie = %d, want 400", code)
+	}
+	if code := callback(state, otherCookie); code != http.StatusBadRequest {
+		t.Errorf("callback with another login's cookie = %d, want 400", code)
+	}
+	if tokens != 0 {
+		t.Fatalf("onToken called %d times for rejected callbacks", tokens)
+	}
+	if code := callback(state, cookie); code != http.StatusOK {
+		t.Errorf("callback with matching cookie = %d, want 200", code)
+	}
+	if code := callback(state, cookie); code != http.StatusBadRequest {
+		t.Errorf("replayed callback = %d, want 400", code)
+	}
+	if tokens != 1 {
+		t.Errorf("onToken called %d times, want 1", tokens)
+	}
+}
@@ -0,0 +1,199 @@
+package googleoauth2callback
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// yamlToJSON converts the YAML subset a Config needs into JSON: top-level
+// "key: value" pairs whose values are scalars, flow sequences ([a, b]) or
+// block sequences of scalars ("- item" lines), with comments. Anything else,
+// such as nested mappings or anchors, is rejected rather than misread.
+func yamlToJSON(r io.Reader) ([]byte, error) {
+	fields := make(map[string]any)
+	var order []string
+	var listKey string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(stripYAMLComment(scanner.Text()), " \t")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || (n == 1 && trimmed == "---") {
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n)
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok || trimmed == "-" {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item outside a list", n)
+			}
+			v, err := yamlScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("line %d: list items must be strings", n)
+			}
+			fields[listKey] = append(fields[listKey].([]string), s)
+			continue
+		}
+		if line != trimmed {
+			return nil, fmt.Errorf("line %d: nested mappings are not supported", n)
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || key == "" || strings.ContainsAny(key, " \t\"'") {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+		if value != "" && value[0] != ' ' {
+			return nil, fmt.Errorf("line %d: expected a space after %q", n, key+":")
+		}
+		if _, dup := fields[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, key)
+		}
+		order = append(order, key)
+		listKey = ""
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			listKey = key
+			fields[key] = []string{}
+		case strings.HasPrefix(value, "["):
+			items, err := yamlFlowSequence(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			fields[key] = items
+		default:
+			v, err := yamlScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			fields[key] = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range order {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(fields[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// stripYAMLComment removes a "#" comment that is not inside quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlScalar returns the value of a plain or quoted scalar: a string, a bool
+// for true or false, or nil for null.
+func yamlScalar(s string) (any, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-quoted string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("invalid single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case s == "null" || s == "~" || s == "":
+		return nil, nil
+	case strings.ContainsAny(s[:1], "[]{}&*!|>%@`"):
+		return nil, fmt.Errorf("unsupported value %s", s)
+	}
+	return s, nil
+}
+
+func yamlFlowSequence(s string) ([]string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated list %s", s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	items := []string{}
+	if inner == "" {
+		return items, nil
+	}
+	for _, part := range splitYAMLFlow(inner) {
+		v, err := yamlScalar(part)
+		if err != nil {
+			return nil, err
+		}
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("list items must be strings")
+		}
+		items = append(items, str)
+	}
+	return items, nil
+}
+
+// splitYAMLFlow splits the items of a flow sequence on commas outside quotes.
+func splitYAMLFlow(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}